@@ -0,0 +1,31 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Access checks as the effective user, which is who dir acts as if it is setuid or setgid.
+
+import "syscall"
+
+const (
+	_AT_FDCWD   = -100
+	_AT_EACCESS = 0x200
+)
+
+// Whether the effective user has the access to the file, as access(2) bits.
+func accessCheck(filename string, mode uint32) error {
+	return syscall.Faccessat(_AT_FDCWD, filename, mode, _AT_EACCESS)
+}
@@ -0,0 +1,29 @@
+//go:build !linux && !windows
+
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Access checks without faccessat(AT_EACCESS), which the syscall package only has on Linux.
+
+import "syscall"
+
+// Whether the user has the access to the file, as access(2) bits.  access(2) asks for the real
+// user, not the effective one; they only differ if dir is run setuid or setgid.
+func accessCheck(filename string, mode uint32) error {
+	return syscall.Access(filename, mode)
+}
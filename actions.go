@@ -0,0 +1,266 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Actions on the files listed: -exec=, -copyto=, -moveto=, -trash and -extractto=.  They run after
+// the listing, so -confirm=once can show the count first, and -dry-run only says what they'd do.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// One kind of action.
+type action struct {
+	verb     string                  // For messages, e.g. "copy"
+	applies  func(f fileitem) bool   // Whether it can be done to this file
+	describe func(f fileitem) string // e.g. "a.txt to /backup/a.txt"
+	run      func(f fileitem) error
+}
+
+var (
+	actions       []action
+	actionTargets []fileitem // Everything listed, in listing order
+	dry_run       bool
+	confirm_mode  string // "", "each" or "once"
+	answers       *bufio.Reader
+)
+
+func fullPath(f fileitem) string {
+	return filepath.Join(f.Path, f.Name)
+}
+
+func onDisk(f fileitem) bool {
+	return !f.InArchive
+}
+
+func diskFile(f fileitem) bool {
+	return !f.InArchive && !f.IsDir
+}
+
+// Adds an action from its flag.
+func addAction(flag string, value string) {
+	switch flag {
+	case "exec":
+		args := splitArgs(value)
+		if len(args) == 0 {
+			conditionalPrint(true, "-exec needs a command.\n")
+			os.Exit(1)
+		}
+		actions = append(actions, action{"run", onDisk,
+			func(f fileitem) string { return strings.Join(execArgs(args, f), " ") },
+			func(f fileitem) error {
+				command := execArgs(args, f)
+				cmd := exec.Command(command[0], command[1:]...)
+				cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+				flushOutput() // Ahead of what the command prints
+				return cmd.Run()
+			}})
+	case "copyto":
+		actions = append(actions, action{"copy", diskFile,
+			func(f fileitem) string { return fullPath(f) + " to " + filepath.Join(value, f.Name) },
+			func(f fileitem) error { return copyFile(fullPath(f), filepath.Join(value, f.Name), f) }})
+	case "moveto":
+		actions = append(actions, action{"move", onDisk,
+			func(f fileitem) string { return fullPath(f) + " to " + filepath.Join(value, f.Name) },
+			func(f fileitem) error { return moveFile(fullPath(f), filepath.Join(value, f.Name), f) }})
+	case "trash":
+		actions = append(actions, action{"trash", onDisk,
+			func(f fileitem) string { return fullPath(f) },
+			func(f fileitem) error { return trashFile(fullPath(f)) }})
+	case "extractto":
+		actions = append(actions, action{"extract", func(f fileitem) bool { return f.InArchive && !f.IsDir },
+			func(f fileitem) string { return f.Name + " from " + f.Path + " to " + filepath.Join(value, f.Name) },
+			func(f fileitem) error { return extractMember(f, value) }})
+	}
+}
+
+// The command for -exec, with {} replaced by the file's path, or the path added at the end.
+func execArgs(args []string, f fileitem) []string {
+	command := make([]string, 0, len(args)+1)
+	replaced := false
+	for _, a := range args {
+		if strings.Contains(a, "{}") {
+			a = strings.ReplaceAll(a, "{}", fullPath(f))
+			replaced = true
+		}
+		command = append(command, a)
+	}
+	if !replaced {
+		command = append(command, fullPath(f))
+	}
+	return command
+}
+
+// Holds the listed files for the actions.
+func queueForActions(files []fileitem) {
+	if len(actions) > 0 {
+		actionTargets = append(actionTargets, files...)
+	}
+}
+
+// Asks a yes/no question.  a (all) stops asking, q stops everything.
+func ask(question string) string {
+	if answers == nil {
+		answers = bufio.NewReader(os.Stdin)
+	}
+	fmt.Fprintf(output, "%s [y]es, [n]o, [a]ll, [q]uit: ", question)
+	flushOutput()
+	answer, err := answers.ReadString('\n')
+	if err != nil {
+		return "q"
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if len(answer) == 0 {
+		return "n"
+	}
+	return answer[:1]
+}
+
+// Does the actions to everything listed, in order.
+func runActions() {
+	if len(actions) == 0 {
+		return
+	}
+	count := 0
+	for _, a := range actions {
+		for _, f := range actionTargets {
+			if a.applies(f) {
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return
+	}
+	fmt.Fprintln(output)
+	askEach := confirm_mode == "each"
+	if confirm_mode == "once" && !dry_run {
+		if answer := ask(fmt.Sprintf("%d actions on %d listed files.  Go ahead?", count, len(actionTargets))); answer != "y" && answer != "a" {
+			return
+		}
+	}
+	done, failed := 0, 0
+	for _, a := range actions {
+		for _, f := range actionTargets {
+			if !a.applies(f) {
+				continue
+			}
+			description := a.verb + " " + a.describe(f)
+			if dry_run {
+				fmt.Fprintln(output, "Would "+description)
+				continue
+			}
+			if askEach {
+				switch ask(strings.ToUpper(description[:1]) + description[1:] + "?") {
+				case "q":
+					return
+				case "a":
+					askEach = false
+				case "y":
+				default:
+					continue
+				}
+			}
+			conditionalPrint(debug_messages, "%s\n", description)
+			if err := a.run(f); err != nil {
+				fmt.Fprintf(output, "Could not %s: %s\n", description, err.Error())
+				failed++
+			} else {
+				done++
+			}
+		}
+	}
+	if !dry_run && size_calculations {
+		fmt.Fprintf(output, "   %4d done, %d failed.\n", done, failed)
+	}
+}
+
+// Copies a file, keeping its permissions and modification time.  Never overwrites.
+func copyFile(from string, to string, f fileitem) error {
+	if _, err := os.Lstat(to); err == nil {
+		return errors.New(to + " already exists")
+	}
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	if err = os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	dest, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dest, source); err != nil {
+		dest.Close()
+		os.Remove(to)
+		return err
+	}
+	if err = dest.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(to, f.Modified, f.Modified)
+}
+
+// Moves a file or directory, copying (files only) if it's going to another file system.  Never overwrites.
+func moveFile(from string, to string, f fileitem) error {
+	if _, err := os.Lstat(to); err == nil {
+		return errors.New(to + " already exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	err := os.Rename(from, to)
+	if errors.Is(err, syscall.EXDEV) && !f.IsDir {
+		if err = copyFile(from, to, f); err == nil {
+			err = os.Remove(from)
+		}
+	}
+	return err
+}
+
+// Writes an archive member under dir, keeping its path within the archive.  Paths that would
+// escape dir, like ../../etc/passwd, are refused.
+func extractMember(f fileitem, dir string) error {
+	to := filepath.Join(dir, filepath.FromSlash(f.Name))
+	if rel, err := filepath.Rel(dir, to); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(f.Name) {
+		return errors.New("member path leaves " + dir)
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return errors.New(to + " already exists")
+	}
+	data, err := archiveMemberBytes(f)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(to, data, 0644|f.Mode.Perm()&0111); err != nil {
+		return err
+	}
+	return os.Chtimes(to, f.Modified, f.Modified)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Limits on archive scanning, so a crafted archive (a zip bomb, or millions of members) can't
+// hang a -z -r sweep or exhaust memory.
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	maxArchiveMemberBytes = 1000000  // Largest member read into memory for a text search
+	minExpansionChecked   = 10 << 20 // Expansion below this is never suspicious, however compressible
+)
+
+var (
+	archive_max_ratio   int64         = 100    // Expanded size over archive size
+	archive_max_members int           = 100000 // Members listed per archive
+	archive_max_depth   int           = 1      // Archives opened inside archives - e.g. a .docx in a .zip
+	archive_time_budget time.Duration = 0      // Total time for all archives.  0 is no limit.
+	archiveTimeSpent    time.Duration
+	archiveStarted      time.Time // When the current archive was opened
+)
+
+// Tracks one archive as its members are read.
+type archiveGuard struct {
+	name       string
+	compressed int64 // Size of the archive file
+	expanded   int64 // Uncompressed size of the members so far
+	members    int
+	tripped    bool
+}
+
+func newArchiveGuard(filename string) *archiveGuard {
+	guard := archiveGuard{name: filename}
+	if fi, err := os.Stat(filename); err == nil {
+		guard.compressed = fi.Size()
+	}
+	return &guard
+}
+
+// Counts a member, returning false (once reporting why) if the archive should not be read further.
+func (g *archiveGuard) allow(size int64) bool {
+	if g.tripped {
+		return false
+	}
+	g.members++
+	g.expanded += size
+	reason := ""
+	if archive_max_members > 0 && g.members > archive_max_members {
+		reason = fmt.Sprintf("more than %d members", archive_max_members)
+	} else if archive_max_ratio > 0 && g.expanded > minExpansionChecked && g.expanded/archive_max_ratio > g.compressed {
+		reason = fmt.Sprintf("expands more than %d times", archive_max_ratio)
+	} else if archiveOverBudget() {
+		reason = fmt.Sprintf("archive time limit of %s reached", archive_time_budget)
+	}
+	if len(reason) > 0 {
+		g.tripped = true
+		conditionalPrint(!bare || show_errors, "Stopped reading %s: %s.\n", g.name, reason)
+	}
+	return !g.tripped
+}
+
+// Starts the clock on an archive.  Returns false if the time budget is already used up.
+func beginArchive(filename string) bool {
+	if archiveOverBudget() {
+		conditionalPrint(show_errors, "Skipping %s: archive time limit of %s reached.\n", filename, archive_time_budget)
+		return false
+	}
+	archiveStarted = time.Now()
+	return true
+}
+
+func endArchive() {
+	archiveTimeSpent += time.Since(archiveStarted)
+	archiveStarted = time.Time{}
+}
+
+func archiveOverBudget() bool {
+	if archive_time_budget <= 0 {
+		return false
+	}
+	spent := archiveTimeSpent
+	if !archiveStarted.IsZero() {
+		spent += time.Since(archiveStarted)
+	}
+	return spent > archive_time_budget
+}
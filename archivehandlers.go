@@ -0,0 +1,354 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Archive formats.  Each is an ArchiveHandler, registered at startup, so listing, searching and
+// extracting members don't need to know which formats exist.  Another format (rar, iso, zst...)
+// is a type with the three methods and a registerArchiveHandler call in its init.
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/gobwas/glob"
+)
+
+type ArchiveHandler interface {
+	// The kind of archive, as -version=json lists them.
+	Name() string
+	// Whether this handler reads the file, going by its name.
+	Detect(filename string) bool
+	// The members of the archive that keep accepts.  Members read before an error are returned with it.
+	List(filename string, keep func(*fileitem) bool) ([]fileitem, error)
+	// A reader for one member.  Closing it closes the archive.
+	Open(archive string, member string) (io.ReadCloser, error)
+}
+
+var archiveHandlers []ArchiveHandler // In the order they're asked
+
+func registerArchiveHandler(handler ArchiveHandler) {
+	archiveHandlers = append(archiveHandlers, handler)
+}
+
+// The handler for an archive, or nil if no handler reads it.
+func archiveHandlerFor(filename string) ArchiveHandler {
+	for _, handler := range archiveHandlers {
+		if handler.Detect(filename) {
+			return handler
+		}
+	}
+	return nil
+}
+
+func init() {
+	registerArchiveHandler(zipHandler{})
+	registerArchiveHandler(tgzHandler{})
+	registerArchiveHandler(sevenZipHandler{})
+}
+
+// Lower-case extension, for Detect.
+func archiveExtension(filename string) string {
+	return strings.ToLower(filename[strings.LastIndex(filename, ".")+1:])
+}
+
+// A member, and what has to be closed along with it.
+type memberReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m memberReader) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func memberNotFound(archive string, member string) error {
+	return &fs.PathError{Op: "open", Path: archive + ":" + member, Err: fs.ErrNotExist}
+}
+
+// Reads a whole member, of a known size.
+func readMember(handler ArchiveHandler, archive string, member string, size int64) ([]byte, error) {
+	reader, err := handler.Open(archive, member)
+	if err != nil {
+		conditionalPrint(show_errors, "Error: Could not open %s.  %s\n", member, err.Error())
+		return nil, err
+	}
+	defer reader.Close()
+	buffer := make([]byte, size)
+	_, err = io.ReadFull(reader, buffer) // A single Read may stop short
+	if err == io.EOF && size == 0 {
+		err = nil
+	}
+	return buffer, err
+}
+
+// Reads a whole member out of the archive it's listed in.
+func archiveMemberBytes(target fileitem) ([]byte, error) {
+	handler := archiveHandlerFor(target.Path)
+	if handler == nil {
+		return nil, fmt.Errorf("no handler for %s", target.Path)
+	}
+	return readMember(handler, target.Path, target.Name, target.Size)
+}
+
+// Reads an archive's members into a listing, with the counts.
+func listArchive(handler ArchiveHandler, filename string) (ListingSet, error) {
+	var ls ListingSet
+	var err error
+	ls.MatchedFiles, err = handler.List(filename, fileMeetsConditions)
+	for _, item := range ls.MatchedFiles {
+		if item.IsDir {
+			ls.Directorycount++
+		} else {
+			ls.Filecount++
+			ls.Bytesfound += item.Size
+		}
+	}
+	return ls, err
+}
+
+// Counts an archive's files and their total size from its headers, for the u column.  A tgz has
+// to be decompressed to find its headers.  Both stop at the archive guards.
+func archiveMemberTotals(filename string) (members int, size int64, err error) {
+	handler := archiveHandlerFor(filename)
+	if handler == nil {
+		return 0, 0, fmt.Errorf("no handler for %s", filename)
+	}
+	_, err = handler.List(filename, func(item *fileitem) bool {
+		if !item.IsDir {
+			members++
+			size += item.Size
+		}
+		return false
+	})
+	return members, size, err
+}
+
+var (
+	member_pattern string    // -zhas
+	member_matcher glob.Glob // Compiled from it: only archives with a member matching this
+)
+
+// Whether any member of the archive matches -zhas, by its name or its path in the archive.  Reading
+// stops at the first, or at the archive guards.
+func archiveHasMember(filename string) bool {
+	handler := archiveHandlerFor(filename)
+	if handler == nil {
+		return false
+	}
+	found := false
+	handler.List(filename, func(item *fileitem) bool {
+		if found || item.IsDir {
+			return false
+		}
+		name := ternaryString(case_sensitive, item.Name, strings.ToUpper(item.Name))
+		found = member_matcher.Match(name) || member_matcher.Match(path.Base(name))
+		return false
+	})
+	return found
+}
+
+type zipHandler struct{}
+
+func (zipHandler) Name() string {
+	return "zip"
+}
+
+func (zipHandler) Detect(filename string) bool {
+	return archiveExtension(filename) == "zip"
+}
+
+func (zipHandler) List(filename string, keep func(*fileitem) bool) ([]fileitem, error) {
+	var items []fileitem
+	zipReader, err := zip.OpenReader(filename)
+	if err != nil {
+		conditionalPrint(show_errors, "Error: Could not open %s.  %s\n", filename, err.Error())
+		return items, err
+	}
+	defer zipReader.Close()
+
+	guard := newArchiveGuard(filename)
+	for _, fileInZip := range zipReader.File {
+		if !guard.allow(int64(fileInZip.UncompressedSize64)) {
+			break
+		}
+		var item fileitem = fileitem{Path: filename, Name: fileInZip.Name, Size: int64(fileInZip.UncompressedSize64), Modified: fileInZip.ModTime(),
+			IsDir: fileInZip.FileInfo().IsDir(), Mode: fileInZip.Mode(), InArchive: true, Compressed: int64(fileInZip.CompressedSize64)}
+		if keep(&item) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (zipHandler) Open(archive string, member string) (io.ReadCloser, error) {
+	zipReader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileInZip := range zipReader.File {
+		if fileInZip.Name == member {
+			reader, err := fileInZip.Open()
+			if err != nil {
+				zipReader.Close()
+				return nil, err
+			}
+			return memberReader{reader, []io.Closer{reader, zipReader}}, nil
+		}
+	}
+	zipReader.Close()
+	return nil, memberNotFound(archive, member)
+}
+
+type sevenZipHandler struct{}
+
+func (sevenZipHandler) Name() string {
+	return "7z"
+}
+
+func (sevenZipHandler) Detect(filename string) bool {
+	return archiveExtension(filename) == "7z"
+}
+
+func (sevenZipHandler) List(filename string, keep func(*fileitem) bool) ([]fileitem, error) {
+	var items []fileitem
+	zipReader, err := sevenzip.OpenReader(filename)
+	if err != nil {
+		conditionalPrint(show_errors, "Error: Could not open %s.  %s\n", filename, err.Error())
+		return items, err
+	}
+	defer zipReader.Close()
+
+	guard := newArchiveGuard(filename)
+	for _, fileInZip := range zipReader.File {
+		if !guard.allow(fileInZip.FileInfo().Size()) {
+			break
+		}
+		var item fileitem = fileitem{Path: filename, Name: fileInZip.Name, Size: fileInZip.FileInfo().Size(),
+			Modified: fileInZip.Modified, IsDir: fileInZip.FileInfo().IsDir(), Mode: fileInZip.Mode(), InArchive: true,
+			Compressed: -1} // 7z compresses members together
+		if keep(&item) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (sevenZipHandler) Open(archive string, member string) (io.ReadCloser, error) {
+	zipReader, err := sevenzip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileInZip := range zipReader.File {
+		if fileInZip.Name == member {
+			reader, err := fileInZip.Open()
+			if err != nil {
+				zipReader.Close()
+				return nil, err
+			}
+			return memberReader{reader, []io.Closer{reader, zipReader}}, nil
+		}
+	}
+	zipReader.Close()
+	return nil, memberNotFound(archive, member)
+}
+
+// Gzipped tars, which have to be read through to find anything.
+type tgzHandler struct{}
+
+func (tgzHandler) Name() string {
+	return "tgz"
+}
+
+func (tgzHandler) Detect(filename string) bool {
+	extension := archiveExtension(filename)
+	return extension == "tgz" || extension == "gz"
+}
+
+// The tar inside, and what to close when done with it.
+func openTgz(filename string) (*tar.Reader, memberReader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, memberReader{}, err
+	}
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, memberReader{}, err
+	}
+	tarReader := tar.NewReader(gzReader)
+	return tarReader, memberReader{tarReader, []io.Closer{gzReader, file}}, nil
+}
+
+func (tgzHandler) List(filename string, keep func(*fileitem) bool) ([]fileitem, error) {
+	var items []fileitem
+	tarReader, closer, err := openTgz(filename)
+	if err != nil {
+		conditionalPrint(show_errors, "Error: Could not open %s.  %s\n", filename, err.Error())
+		return items, err
+	}
+	defer closer.Close()
+
+	guard := newArchiveGuard(filename)
+	head, err := tarReader.Next()
+	for head != nil && err == nil && guard.allow(head.Size) {
+		var item fileitem = fileitem{Path: filename, Name: head.Name, Size: head.Size, Modified: head.ModTime,
+			IsDir: head.FileInfo().IsDir(), Mode: head.FileInfo().Mode(), InArchive: true, Owner: head.Uname, Group: head.Gname,
+			Compressed: -1} // The whole tar is compressed
+		if keep(&item) {
+			items = append(items, item)
+		}
+		head, err = tarReader.Next()
+	}
+	if err == io.EOF { // The normal end
+		err = nil
+	}
+	return items, err
+}
+
+func (tgzHandler) Open(archive string, member string) (io.ReadCloser, error) {
+	tarReader, closer, err := openTgz(archive)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		head, err := tarReader.Next()
+		if err != nil {
+			closer.Close()
+			if err == io.EOF {
+				return nil, memberNotFound(archive, member)
+			}
+			return nil, err
+		}
+		if head.Name == member {
+			return closer, nil
+		}
+	}
+}
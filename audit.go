@@ -0,0 +1,160 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -audit=secrets: a report of files that may hold secrets, worst first.  Files are found by name,
+// as for -sensitive, and with -audit=secrets,content also by a private key inside them.  How bad
+// each is depends on who else can read it: world-readable is high, group-readable medium, and
+// only the owner low.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	auditLow = iota
+	auditMedium
+	auditHigh
+)
+
+var auditLevels = []string{"LOW", "MEDIUM", "HIGH"}
+
+var (
+	audit_secrets bool // -audit=secrets
+	audit_content bool // Also look inside files for private keys
+)
+
+var privateKeyHeader = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----`)
+
+type auditFinding struct {
+	file     fileitem
+	level    int
+	keyFound bool // A private key is in it
+	reasons  []string
+}
+
+// Sets up the audit from -audit=secrets or -audit=secrets,content.
+func parseAudit(value string) {
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+		switch strings.TrimSpace(part) {
+		case "secrets":
+			audit_secrets = true
+		case "content":
+			audit_content = true
+		default:
+			conditionalPrint(true, "Unknown -audit=%s; use secrets, or secrets,content.\n", value)
+			os.Exit(1)
+		}
+	}
+	if !audit_secrets {
+		conditionalPrint(true, "-audit needs secrets, e.g. -audit=secrets,content.\n")
+		os.Exit(1)
+	}
+	recurse_directories = true
+	sensitive_only = !audit_content // Otherwise every file has to be looked in
+}
+
+// Whether a private key is in the file.  Keys are small, so only the first 1MB is read.
+func holdsPrivateKey(f fileitem) bool {
+	if f.IsDir || f.Size == 0 {
+		return false
+	}
+	var data []byte
+	var err error
+	if f.InArchive {
+		if f.Size > maxArchiveMemberBytes {
+			return false
+		}
+		data, err = archiveMemberBytes(f)
+	} else {
+		var file *os.File
+		if file, err = os.Open(filepath.Join(f.Path, f.Name)); err == nil {
+			data, err = io.ReadAll(io.LimitReader(file, maxArchiveMemberBytes))
+			file.Close()
+		}
+	}
+	return err == nil && privateKeyHeader.Match(data)
+}
+
+// How bad the file is, and why, or nil if it's not a finding.
+func auditFile(f fileitem) *auditFinding {
+	finding := auditFinding{file: f}
+	if kind := f.SensitiveKind(); len(kind) > 0 {
+		finding.reasons = append(finding.reasons, kind)
+	}
+	if audit_content && holdsPrivateKey(f) {
+		finding.keyFound = true
+		finding.reasons = append(finding.reasons, "private key inside")
+	}
+	if len(finding.reasons) == 0 {
+		return nil
+	}
+	switch {
+	case runtime.GOOS == "windows" || f.InArchive: // The mode bits don't say who can read it
+	case f.Mode&0004 != 0:
+		finding.level = auditHigh
+		finding.reasons = append(finding.reasons, "world-readable")
+	case f.Mode&0040 != 0:
+		finding.level = auditMedium
+		finding.reasons = append(finding.reasons, "group-readable")
+	}
+	return &finding
+}
+
+// Prints the findings, worst first: by level, then those with a key inside, then by path.
+func printAudit(files []fileitem) {
+	var findings []auditFinding
+	for _, f := range files {
+		if finding := auditFile(f); finding != nil {
+			findings = append(findings, *finding)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].level != findings[j].level {
+			return findings[i].level > findings[j].level
+		}
+		if findings[i].keyFound != findings[j].keyFound {
+			return findings[i].keyFound
+		}
+		return filepath.Join(findings[i].file.Path, findings[i].file.Name) < filepath.Join(findings[j].file.Path, findings[j].file.Name)
+	})
+	if directory_header {
+		fmt.Fprintf(output, "\n   Secrets audit of %s\n\n", displayPath(start_directory))
+	}
+	counts := make([]int, len(auditLevels))
+	for _, finding := range findings {
+		f := finding.file
+		counts[finding.level]++
+		path := displayPath(filepath.Join(f.Path, f.Name))
+		if bare {
+			fmt.Fprintln(output, path)
+			continue
+		}
+		fmt.Fprintf(output, "%-6s  %s  %-10s  %s  (%s)\n", auditLevels[finding.level], f.ModeToString(), f.Owner, path,
+			strings.Join(finding.reasons, ", "))
+	}
+	if size_calculations {
+		fmt.Fprintf(output, "\n   %4d findings: %d high, %d medium, %d low.\n", len(findings), counts[auditHigh], counts[auditMedium], counts[auditLow])
+	}
+}
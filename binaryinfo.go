@@ -0,0 +1,296 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Executable inspection for the optional binary columns.
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	peDebugDirectory    = 6          // IMAGE_DIRECTORY_ENTRY_DEBUG
+	peResourceDirectory = 2          // IMAGE_DIRECTORY_ENTRY_RESOURCE
+	peResourceVersion   = 16         // RT_VERSION
+	peFixedInfoMagic    = 0xFEEF04BD // VS_FIXEDFILEINFO.dwSignature
+	peFixedInfoSize     = 52         // sizeof(VS_FIXEDFILEINFO)
+)
+
+// Extensions that may carry a Windows version resource.
+var peExtensions = ",exe,dll,sys,ocx,cpl,scr,efi,mui,"
+
+// Extensions worth a code signature check, beyond anything marked executable.
+var signedExtensions = ",app,appx,cab,dll,dylib,exe,kext,msi,msix,pkg,ps1,sys,"
+
+// Returns "FileVersion/ProductVersion" from the PE version resource, or "" if there isn't one.
+// This is pure Go, so Windows binaries can be audited from any platform.
+func (f fileitem) VersionInfo() string {
+	if f.IsDir || f.InArchive || !strings.Contains(peExtensions, ","+strings.ToLower(f.Extension())+",") {
+		return ""
+	}
+	fileVersion, productVersion, err := peVersion(filepath.Join(f.Path, f.Name))
+	if err != nil {
+		conditionalPrint(debug_messages, "No version info for %s: %s\n", f.Name, err.Error())
+		return ""
+	}
+	return fileVersion + "/" + productVersion
+}
+
+// Returns format, architecture and stripped status of an ELF, Mach-O or PE file,
+// e.g. "ELF amd64 stripped".  Returns "" for anything else.
+func (f fileitem) BinaryInfo() string {
+	if f.IsDir || f.InArchive || f.Size < 64 {
+		return ""
+	}
+	filename := filepath.Join(f.Path, f.Name)
+	file, err := os.Open(filename)
+	if err != nil {
+		conditionalPrint(show_errors, "Could not open %s: %s\n", f.Name, err.Error())
+		return ""
+	}
+	defer file.Close()
+	magic := make([]byte, 4)
+	if _, err = file.ReadAt(magic, 0); err != nil {
+		return ""
+	}
+
+	var format, arch string
+	stripped := false
+	switch {
+	case bytes.Equal(magic, []byte(elf.ELFMAG)):
+		ef, e := elf.NewFile(file)
+		if e != nil {
+			return ""
+		}
+		format, arch = "ELF", elfArch(ef)
+		stripped = ef.Section(".symtab") == nil
+	case bytes.Equal(magic, []byte{0xca, 0xfe, 0xba, 0xbe}): // Fat Mach-O, but also Java classes.
+		ff, e := macho.NewFatFile(file)
+		if e != nil {
+			return ""
+		}
+		var arches []string
+		for _, a := range ff.Arches {
+			arches = append(arches, machoArch(a.Cpu))
+			stripped = stripped || a.Symtab == nil || len(a.Symtab.Syms) == 0
+		}
+		format, arch = "Mach-O", strings.Join(arches, ",")
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		pf, e := pe.NewFile(file)
+		if e != nil {
+			return ""
+		}
+		format, arch = "PE", peArch(pf.Machine)
+		debug := pe.DataDirectory{}
+		if dirs := peDataDirectories(pf); len(dirs) > peDebugDirectory {
+			debug = dirs[peDebugDirectory]
+		}
+		stripped = pf.NumberOfSymbols == 0 && debug.Size == 0
+	default:
+		mf, e := macho.NewFile(file)
+		if e != nil {
+			return ""
+		}
+		format, arch = "Mach-O", machoArch(mf.Cpu)
+		stripped = mf.Symtab == nil || len(mf.Symtab.Syms) == 0
+	}
+	return format + " " + arch + ternaryString(stripped, " stripped", "")
+}
+
+// Returns signed/notarized/unsigned/INVALID from the platform's signature checker, for
+// executables only.  Empty where unsupported (i.e. not macOS or Windows.)
+func (f fileitem) SignatureStatus() string {
+	if f.InArchive {
+		return ""
+	}
+	signable := strings.Contains(signedExtensions, ","+strings.ToLower(f.Extension())+",")
+	if !signable && (f.IsDir || f.Mode&0111 == 0) { // .app bundles are directories
+		return ""
+	}
+	return signatureStatus(filepath.Join(f.Path, f.Name))
+}
+
+func elfArch(ef *elf.File) string {
+	switch ef.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_RISCV:
+		return ternaryString(ef.Class == elf.ELFCLASS64, "riscv64", "riscv")
+	case elf.EM_PPC64:
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_MIPS:
+		return "mips"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	}
+	return strings.ToLower(strings.TrimPrefix(ef.Machine.String(), "EM_"))
+}
+
+func machoArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.CpuArm:
+		return "arm"
+	case macho.CpuPpc64:
+		return "ppc64"
+	case macho.CpuPpc:
+		return "ppc"
+	}
+	return cpu.String()
+}
+
+func peArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT, pe.IMAGE_FILE_MACHINE_ARM:
+		return "arm"
+	}
+	return fmt.Sprintf("0x%x", machine)
+}
+
+// The optional header's data directories, trimmed to the count the file declares.
+func peDataDirectories(pf *pe.File) []pe.DataDirectory {
+	var dirs []pe.DataDirectory
+	switch oh := pf.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs = oh.DataDirectory[:]
+		if oh.NumberOfRvaAndSizes < uint32(len(dirs)) {
+			dirs = dirs[:oh.NumberOfRvaAndSizes]
+		}
+	case *pe.OptionalHeader64:
+		dirs = oh.DataDirectory[:]
+		if oh.NumberOfRvaAndSizes < uint32(len(dirs)) {
+			dirs = dirs[:oh.NumberOfRvaAndSizes]
+		}
+	}
+	return dirs
+}
+
+func peVersion(filename string) (string, string, error) {
+	pf, err := pe.Open(filename)
+	if err != nil {
+		return "", "", err
+	}
+	defer pf.Close()
+
+	dirs := peDataDirectories(pf)
+	if len(dirs) <= peResourceDirectory || dirs[peResourceDirectory].VirtualAddress == 0 {
+		return "", "", errors.New("no resources")
+	}
+	rva := dirs[peResourceDirectory].VirtualAddress
+	var rsrc *pe.Section
+	for _, s := range pf.Sections {
+		if rva >= s.VirtualAddress && rva < s.VirtualAddress+s.VirtualSize {
+			rsrc = s
+			break
+		}
+	}
+	if rsrc == nil {
+		return "", "", errors.New("resource section not found")
+	}
+	data, err := rsrc.Data()
+	if err != nil {
+		return "", "", err
+	}
+	base := rva - rsrc.VirtualAddress
+	if int(base) >= len(data) {
+		return "", "", errors.New("resource directory out of range")
+	}
+	data = data[base:]
+
+	// Three levels: type, name, language.  Take the first name and language under RT_VERSION.
+	offset, ok := peResourceEntry(data, 0, peResourceVersion)
+	for level := 1; ok && level < 3; level++ {
+		offset, ok = peResourceEntry(data, offset, -1)
+	}
+	if !ok || int(offset)+8 > len(data) {
+		return "", "", errors.New("no version resource")
+	}
+	// IMAGE_RESOURCE_DATA_ENTRY holds an RVA, not an offset into the section.
+	dataRVA := binary.LittleEndian.Uint32(data[offset:])
+	size := binary.LittleEndian.Uint32(data[offset+4:])
+	start := int(dataRVA) - int(rva)
+	if start < 0 || start+int(size) > len(data) {
+		return "", "", errors.New("version resource out of range")
+	}
+	info := data[start : start+int(size)]
+
+	for i := 0; i+peFixedInfoSize <= len(info); i += 4 { // VS_FIXEDFILEINFO is DWORD aligned, and all there
+		if binary.LittleEndian.Uint32(info[i:]) != peFixedInfoMagic {
+			continue
+		}
+		return peVersionString(info[i+8:]), peVersionString(info[i+16:]), nil
+	}
+	return "", "", errors.New("no fixed file info")
+}
+
+// Finds the entry with the given id (or the first entry if id < 0) in the resource
+// directory at offset.  Returns the offset of what it points to.
+func peResourceEntry(data []byte, offset uint32, id int) (uint32, bool) {
+	if int(offset)+16 > len(data) {
+		return 0, false
+	}
+	named := int(binary.LittleEndian.Uint16(data[offset+12:]))
+	ids := int(binary.LittleEndian.Uint16(data[offset+14:]))
+	for i := 0; i < named+ids; i++ {
+		entry := int(offset) + 16 + i*8
+		if entry+8 > len(data) {
+			return 0, false
+		}
+		name := binary.LittleEndian.Uint32(data[entry:])
+		target := binary.LittleEndian.Uint32(data[entry+4:])
+		if id >= 0 && (name&0x80000000 != 0 || int(name) != id) {
+			continue
+		}
+		return target &^ 0x80000000, true
+	}
+	return 0, false
+}
+
+// Two DWORDs, most significant first, as a.b.c.d
+func peVersionString(b []byte) string {
+	ms := binary.LittleEndian.Uint32(b)
+	ls := binary.LittleEndian.Uint32(b[4:])
+	return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xFFFF, ls>>16, ls&0xFFFF)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Verification of files against sidecar checksum files - foo.zip.sha256, SHA256SUMS, MD5SUMS, etc.
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Sidecar extensions; a file foo.zip.sha256 holds the sum for foo.zip.
+var sidecarExtensions = ",md5,sha1,sha256,sha512,md5sum,sha1sum,sha256sum,sha512sum,"
+
+// Files of sums for a directory, in upper case, as sha256sum and the like write them.  They may
+// also end in .txt.
+var sidecarNames = []string{"MD5SUMS", "SHA1SUMS", "SHA224SUMS", "SHA256SUMS", "SHA384SUMS", "SHA512SUMS",
+	"SHASUMS", "SHASUMS1", "SHASUMS256", "SHASUMS512", "CHECKSUMS"}
+
+// The algorithms, by the names the tools and BSD-style lines give them.
+var hashers = map[string]func() hash.Hash{"MD5": md5.New, "SHA1": sha1.New, "SHA224": sha256.New224,
+	"SHA256": sha256.New, "SHA384": sha512.New384, "SHA512": sha512.New}
+
+// A sum from a sidecar, with the algorithm that made it.
+type checksum struct {
+	algorithm string
+	sum       string
+}
+
+// Checksums by file name, per directory.  Loaded on first use.
+var sidecarSums = map[string]map[string]checksum{}
+
+// The algorithm a sidecar's name says it holds: SHA256SUMS, SHASUMS256 or foo.zip.sha256sum.
+// "" for SHASUMS and CHECKSUMS, which may hold any.
+func sidecarAlgorithm(name string) string {
+	upper := strings.ToUpper(name)
+	if ext := upper[strings.LastIndex(upper, ".")+1:]; strings.Contains(sidecarExtensions, ","+strings.ToLower(ext)+",") {
+		return strings.TrimSuffix(ext, "SUM")
+	}
+	upper = strings.TrimSuffix(upper, ".TXT")
+	if bits, ok := strings.CutPrefix(upper, "SHASUMS"); ok && bits != "" {
+		return "SHA" + bits
+	}
+	if _, ok := hashers[strings.TrimSuffix(upper, "SUMS")]; ok {
+		return strings.TrimSuffix(upper, "SUMS")
+	}
+	return ""
+}
+
+// The hasher for a sum made by algorithm, or picked from the length of the hex digest when the
+// sidecar doesn't say.  Nil if the sum can't be one of those.
+func hasherFor(algorithm string, sum string) hash.Hash {
+	if algorithm == "" {
+		algorithm = map[int]string{32: "MD5", 40: "SHA1", 56: "SHA224", 64: "SHA256", 96: "SHA384", 128: "SHA512"}[len(sum)]
+	}
+	newHash, ok := hashers[algorithm]
+	if !ok {
+		return nil
+	}
+	if h := newHash(); 2*h.Size() == len(sum) {
+		return h
+	}
+	return nil
+}
+
+func isSidecar(name string) bool {
+	ext := strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	return strings.Contains(sidecarExtensions, ","+ext+",") || slices.Contains(sidecarNames, strings.TrimSuffix(strings.ToUpper(name), ".TXT"))
+}
+
+// Reads all sidecars in a directory.  Handles GNU ("sum  name" or "sum *name"),
+// BSD ("SHA256 (name) = sum") and bare-sum files.
+func loadSidecars(dir string) map[string]checksum {
+	if sums, ok := sidecarSums[dir]; ok {
+		return sums
+	}
+	sums := map[string]checksum{}
+	sidecarSums[dir] = sums
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return sums
+	}
+	for _, e := range entries {
+		if e.IsDir() || !isSidecar(e.Name()) {
+			continue
+		}
+		file, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			conditionalPrint(show_errors, "Could not read checksum file %s: %s\n", e.Name(), err.Error())
+			continue
+		}
+		named := sidecarAlgorithm(e.Name())
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			var sum, name string
+			algorithm := named
+			if open, eq := strings.Index(line, " ("), strings.LastIndex(line, ") = "); open > 0 && eq > open {
+				name, sum = line[open+2:eq], line[eq+4:]
+				algorithm = strings.ReplaceAll(strings.ToUpper(line[:open]), "-", "") // The line's own tag
+			} else if fields := strings.Fields(line); len(fields) == 1 {
+				// Bare sum; belongs to the file the sidecar is named for.
+				sum, name = fields[0], strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+			} else if len(fields) > 1 {
+				sum, name = fields[0], strings.TrimPrefix(strings.TrimSpace(line[len(fields[0]):]), "*")
+			}
+			if _, err := hex.DecodeString(sum); err != nil || hasherFor(algorithm, sum) == nil {
+				continue
+			}
+			sums[filepath.Base(strings.TrimPrefix(name, "./"))] = checksum{algorithm, strings.ToLower(sum)}
+		}
+		file.Close()
+	}
+	return sums
+}
+
+// Returns OK or FAIL if a sidecar lists this file, "" if not.
+func (f fileitem) SidecarStatus() string {
+	if f.IsDir || f.InArchive {
+		return ""
+	}
+	listed, ok := loadSidecars(f.Path)[f.Name]
+	if !ok {
+		return ""
+	}
+	file, err := os.Open(filepath.Join(f.Path, f.Name))
+	if err != nil {
+		conditionalPrint(show_errors, "Could not open %s to verify: %s\n", f.Name, err.Error())
+		return "FAIL"
+	}
+	defer file.Close()
+	hasher := hasherFor(listed.algorithm, listed.sum)
+	if _, err = io.Copy(hasher, file); err != nil {
+		return "FAIL"
+	}
+	return ternaryString(hex.EncodeToString(hasher.Sum(nil)) == listed.sum, "OK", "FAIL")
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -clones: files that share their data with others - APFS and ReFS clones, Btrfs and XFS
+// reflinks, deduplicated extents - marked with the bytes they share (the C column), and a total
+// that counts shared data once, so what a cloned dataset really takes on disk isn't overstated.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A run of a file's data on disk.
+type extent struct {
+	device   uint64
+	physical uint64
+	length   uint64
+}
+
+var (
+	clones        bool
+	seenExtents   = map[extent]bool{} // Shared extents already counted
+	cloneOnDisk   int64               // Bytes of the files listed, counting shared extents once
+	cloneShared   int64               // Bytes listed that are shared
+	clonesUnknown int                 // Files the file system wouldn't say about
+)
+
+// Fills in f.Shared and adds the file to the totals.  Extents shared with files not listed count
+// in full, once.
+func noteClones(f *fileitem) {
+	if f.IsDir || f.InArchive {
+		return
+	}
+	shared, err := sharedExtents(f.Path, f.Name)
+	if err != nil {
+		conditionalPrint(debug_messages, "No extents for %s: %s\n", f.Name, err.Error())
+		f.Shared = -1
+		clonesUnknown++
+		cloneOnDisk += f.Size
+		return
+	}
+	unique := f.Size
+	for _, e := range shared {
+		f.Shared += int64(e.length)
+		if seenExtents[e] {
+			unique -= int64(e.length)
+		}
+		seenExtents[e] = true
+	}
+	if unique < 0 { // Extents can run past the end of the file
+		unique = 0
+	}
+	cloneOnDisk += unique
+	cloneShared += f.Shared
+}
+
+// The C column: the bytes shared, blank if none, ? if unknown.
+func (f fileitem) SharedString() string {
+	switch {
+	case f.IsDir || f.InArchive || f.Shared == 0:
+		return fmt.Sprintf("%*s", len(FileSizeToString(0)), "")
+	case f.Shared < 0:
+		return fmt.Sprintf("%*s", len(FileSizeToString(0)), "?")
+	}
+	return FileSizeToString(f.Shared)
+}
+
+func printCloneTotals() {
+	fmt.Fprintf(output, "   %s bytes on disk, counting shared data once; %s bytes listed are shared.\n",
+		strings.TrimSpace(FileSizeToString(cloneOnDisk)), strings.TrimSpace(FileSizeToString(cloneShared)))
+	if clonesUnknown > 0 {
+		fmt.Fprintf(output, "   %4d Files on file systems that don't say what they share were counted in full.\n", clonesUnknown)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Shared extents from the FIEMAP ioctl, which Btrfs, XFS, OCFS2 and bcachefs fill in for reflinked
+// and deduplicated data.  ext4 has no sharing, so reports none.
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	FS_IOC_FIEMAP        = 0xC020660B
+	FIEMAP_FLAG_SYNC     = 0x1
+	FIEMAP_EXTENT_LAST   = 0x1
+	FIEMAP_EXTENT_SHARED = 0x2000
+	fiemapExtentsPerCall = 64
+)
+
+type fiemapExtent struct {
+	logical  uint64
+	physical uint64
+	length   uint64
+	_        [2]uint64
+	flags    uint32
+	_        [3]uint32
+}
+
+// struct fiemap, with room for the extents after it.
+type fiemap struct {
+	start         uint64
+	length        uint64
+	flags         uint32
+	mappedExtents uint32
+	extentCount   uint32
+	_             uint32
+	extents       [fiemapExtentsPerCall]fiemapExtent
+}
+
+// The file's extents that are shared with other files.
+func sharedExtents(path string, name string) ([]extent, error) {
+	file, err := os.Open(filepath.Join(path, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	device := uint64(info.Sys().(*syscall.Stat_t).Dev)
+	var shared []extent
+	var request fiemap
+	for start := uint64(0); ; {
+		request = fiemap{start: start, length: ^uint64(0), flags: FIEMAP_FLAG_SYNC, extentCount: fiemapExtentsPerCall}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), FS_IOC_FIEMAP, uintptr(unsafe.Pointer(&request))); errno != 0 {
+			return nil, errno
+		}
+		if request.mappedExtents == 0 {
+			return shared, nil
+		}
+		for _, e := range request.extents[:request.mappedExtents] {
+			if e.flags&FIEMAP_EXTENT_SHARED != 0 {
+				shared = append(shared, extent{device, e.physical, e.length})
+			}
+			if e.flags&FIEMAP_EXTENT_LAST != 0 {
+				return shared, nil
+			}
+			start = e.logical + e.length
+		}
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "errors"
+
+// APFS and ReFS keep which blocks are shared to themselves: macOS only flags that a file may share
+// some, and Windows needs the volume's allocation details.  So sharing is unknown here.
+func sharedExtents(path string, name string) ([]extent, error) {
+	return nil, errors.New("not supported on this platform")
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+const signaturesChecked = true // For -version=json
+
+var (
+	codesignPath = "*" // Uninitialized
+	spctlPath    = "*"
+)
+
+// Uses codesign to check the signature, and spctl to see if Gatekeeper accepts it (i.e. notarized.)
+func signatureStatus(filename string) string {
+	if codesignPath == "*" {
+		codesignPath = resolveCommand("codesign")
+		spctlPath = resolveCommand("spctl")
+		conditionalPrint(debug_messages && len(codesignPath) == 0, "Could not find codesign.  Signatures will not be checked.\n")
+	}
+	if len(codesignPath) == 0 {
+		return ""
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(codesignPath, "--verify", "--strict", filename)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "not signed") {
+			return "unsigned"
+		}
+		conditionalPrint(show_errors || debug_messages, "codesign on %s: %s", filename, stderr.String())
+		return "INVALID"
+	}
+	if len(spctlPath) > 0 && exec.Command(spctlPath, "--assess", "--type", "execute", filename).Run() == nil {
+		return "notarized"
+	}
+	return "signed"
+}
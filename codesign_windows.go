@@ -0,0 +1,52 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+const signaturesChecked = true // For -version=json
+
+var powershellPath = "*" // Uninitialized
+
+// Asks PowerShell for the Authenticode status.  Slow, since it is a process per file.
+func signatureStatus(filename string) string {
+	if powershellPath == "*" {
+		powershellPath = resolveCommand("powershell.exe")
+		conditionalPrint(debug_messages && len(powershellPath) == 0, "Could not find powershell.  Signatures will not be checked.\n")
+	}
+	if len(powershellPath) == 0 {
+		return ""
+	}
+	script := "(Get-AuthenticodeSignature -LiteralPath '" + strings.ReplaceAll(filename, "'", "''") + "').Status"
+	out, err := exec.Command(powershellPath, "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		conditionalPrint(debug_messages, "Get-AuthenticodeSignature on %s: %s\n", filename, err.Error())
+		return ""
+	}
+	switch status := strings.TrimSpace(string(out)); status {
+	case "Valid":
+		return "signed"
+	case "NotSigned":
+		return "unsigned"
+	default: // HashMismatch, NotTrusted, UnknownError...
+		conditionalPrint(show_errors || debug_messages, "Signature of %s: %s\n", filename, status)
+		return "INVALID"
+	}
+}
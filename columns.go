@@ -0,0 +1,142 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Parsing of the -c= column definition, e.g. "p   m  s:10R   n:40L".
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// One piece of a column definition: either a column letter or literal text.
+type columnSpec struct {
+	column  byte   // 0 for literal text
+	literal string // Text to print verbatim
+	width   int    // 0 for natural width
+	align   byte   // 'L' or 'R'
+}
+
+var (
+	parsedColumnDef string // What parsedColumns was parsed from
+	parsedColumns   []columnSpec
+	smart_columns   bool // Leave out columns blank for every file listed
+)
+
+func isColumn(c byte) bool {
+	_, custom := customColumns[c]
+	return strings.IndexByte(builtinColumns, c) >= 0 || custom
+}
+
+// Parses columnDef, caching the result until columnDef changes.  A column letter may be
+// followed by :width and L or R alignment; anything else is literal text.
+func columnSpecs() []columnSpec {
+	if parsedColumns != nil && parsedColumnDef == columnDef {
+		return parsedColumns
+	}
+	parsedColumns = parsedColumns[:0]
+	parsedColumnDef = columnDef
+	for i := 0; i < len(columnDef); i++ {
+		c := columnDef[i]
+		if !isColumn(c) {
+			if n := len(parsedColumns); n > 0 && parsedColumns[n-1].column == 0 {
+				parsedColumns[n-1].literal += string(c)
+			} else {
+				parsedColumns = append(parsedColumns, columnSpec{literal: string(c)})
+			}
+			continue
+		}
+		spec := columnSpec{column: c, align: 'L'}
+		// Optional :width{L|R}.  A colon not followed by digits is just text.
+		if i+2 < len(columnDef) && columnDef[i+1] == ':' && columnDef[i+2] >= '0' && columnDef[i+2] <= '9' {
+			j := i + 2
+			for j < len(columnDef) && columnDef[j] >= '0' && columnDef[j] <= '9' {
+				j++
+			}
+			spec.width, _ = strconv.Atoi(columnDef[i+2 : j])
+			if j < len(columnDef) && (columnDef[j] == 'L' || columnDef[j] == 'R') {
+				spec.align = columnDef[j]
+				j++
+			}
+			i = j - 1
+		}
+		parsedColumns = append(parsedColumns, spec)
+	}
+	return parsedColumns
+}
+
+// Pads or truncates a value to the spec's width.  Built-in padding is dropped first,
+// so e.g. s:10R right-aligns the number itself.
+func (spec columnSpec) fit(value string) string {
+	if spec.width == 0 {
+		return value
+	}
+	value = strings.TrimSpace(value)
+	length := utf8.RuneCountInString(value)
+	if length > spec.width {
+		return string([]rune(value)[:spec.width])
+	}
+	padding := strings.Repeat(" ", spec.width-length)
+	if spec.align == 'R' {
+		return padding + value
+	}
+	return value + padding
+}
+
+// For -smart-columns: the columns without those blank for every file, and each file's values, so
+// slow columns aren't worked out twice.  The text leading into a dropped column goes with it, as
+// do closing brackets after it, so "m  (c)  s" becomes "m  s".
+func smartColumns(files []fileitem) ([]columnSpec, []map[byte]string) {
+	specs := columnSpecs()
+	values := make([]map[byte]string, len(files))
+	blank := map[byte]bool{}
+	for _, spec := range specs {
+		if spec.column != 0 {
+			blank[spec.column] = true
+		}
+	}
+	for i := range files {
+		values[i] = map[byte]string{}
+		for column := range blank {
+			values[i][column] = files[i].ColumnValue(column)
+			if len(strings.TrimSpace(values[i][column])) > 0 {
+				blank[column] = false
+			}
+		}
+	}
+	var kept []columnSpec
+	dropped := false
+	for _, spec := range specs {
+		if spec.column != 0 && blank[spec.column] {
+			if n := len(kept); n > 0 && kept[n-1].column == 0 {
+				kept = kept[:n-1]
+			}
+			dropped = true
+			continue
+		}
+		if dropped && spec.column == 0 {
+			spec.literal = strings.TrimLeft(spec.literal, ")]}>")
+			if len(kept) == 0 {
+				spec.literal = strings.TrimLeft(spec.literal, " \t")
+			}
+		}
+		dropped = false
+		kept = append(kept, spec)
+	}
+	return kept, values
+}
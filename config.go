@@ -0,0 +1,259 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The configuration file.  Lines are "kind name = value" or "name = value"; # starts a comment.
+//   alias recent = -o-d -r -b+
+//   column A = {{days .Modified}}
+//   pdf-helper = /opt/homebrew/bin/mutool
+//   generated = *.gen.ts, schema_*.sql
+//   size-color 10G = 01;35
+//   sensitive = *.tfstate, vault.yml
+//   type code = svelte,vue
+//   color code = 01;34
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const maxAliasDepth = 10 // Aliases may use aliases, but not forever.
+
+var (
+	configPath string                  // Where the config was found, if anywhere
+	aliases    = map[string][]string{} // Alias name to the arguments it expands to
+	// Column letter to its template, from "column" settings.
+	customColumns = map[byte]*template.Template{}
+)
+
+// Functions available to column templates, beyond the fileitem's own fields and methods.
+var columnFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"days":  func(t time.Time) int { return int(time.Since(t).Hours() / 24) },
+	"kb":    func(n int64) string { return fmt.Sprintf("%.1f", float64(n)/1024) },
+	"mb":    func(n int64) string { return fmt.Sprintf("%.1f", float64(n)/(1024*1024)) },
+	"gb":    func(n int64) string { return fmt.Sprintf("%.2f", float64(n)/(1024*1024*1024)) },
+	"date":  func(layout string, t time.Time) string { return t.Format(layout) },
+	"type":  func(f *fileitem) string { return f.FileType().String() },
+}
+
+// Returns the config file location, e.g. ~/.config/dir/dir.conf on Linux, or beside the
+// executable if portable.
+func defaultConfigPath() string {
+	dir := filesDir(os.UserConfigDir)
+	if len(dir) == 0 {
+		return ""
+	}
+	return filepath.Join(dir, "dir.conf")
+}
+
+// Reads the config file, if there is one.  A missing file is not an error.
+func loadConfig(path string) {
+	if readConfigFile(path, applyConfigSetting) {
+		configPath = path
+	}
+}
+
+var (
+	holdConfigErrors = true   // Until the flags are parsed, as the config file is read first
+	configErrors     []string // Held until then
+)
+
+// Reports a bad line in a config file, with -errors.  Those in the config file itself are held
+// until -errors can have been seen.
+func configError(path string, lineNo int, format string, a ...any) {
+	message := fmt.Sprintf("%s:%d: ", path, lineNo) + fmt.Sprintf(format, a...)
+	if holdConfigErrors {
+		configErrors = append(configErrors, message)
+		return
+	}
+	conditionalPrint(show_errors, "%s", message)
+}
+
+// Prints the config file's errors, now that the flags say whether to.
+func reportConfigErrors() {
+	holdConfigErrors = false
+	for _, message := range configErrors {
+		conditionalPrint(show_errors, "%s", message)
+	}
+	configErrors = nil
+}
+
+// Reads a config file's settings into apply.  Returns false if there's no file.
+func readConfigFile(path string, apply func(kind string, name string, value string, path string, lineNo int)) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			configError(path, lineNo, "expected name = value: %s\n", line)
+			continue
+		}
+		kind, name, _ := strings.Cut(strings.TrimSpace(key), " ")
+		apply(kind, strings.TrimSpace(name), strings.TrimSpace(value), path, lineNo)
+	}
+	return true
+}
+
+func applyConfigSetting(kind string, name string, value string, path string, lineNo int) {
+	switch kind {
+	case "alias":
+		aliases[strings.TrimLeft(name, "-/")] = splitArgs(value)
+	case "color": // For the type, as in LS_COLORS
+		if ft, ok := configType(name, path, lineNo); ok {
+			FileColors[ft] = value
+		}
+	case "column":
+		if len(name) != 1 || strings.Contains(builtinColumns, name) {
+			configError(path, lineNo, "column name must be one character, not one of %s\n", builtinColumns)
+			return
+		}
+		tmpl, err := template.New(name).Funcs(columnFuncs).Parse(value)
+		if err != nil {
+			configError(path, lineNo, "%s\n", err.Error())
+			return
+		}
+		customColumns[name[0]] = tmpl
+	case "size-color": // Sizes from name up in this color, for -size-colors
+		if err := addSizeColor(name, value); err != nil {
+			configError(path, lineNo, "%s\n", err.Error())
+		}
+	case "sensitive": // More patterns for the ! column and -sensitive
+		addSensitivePatterns(value)
+	case "generated": // More patterns for -nogenerated
+		addGeneratedPatterns(value)
+	case "pdf-helper":
+		pdf_helper = value
+	case "type": // Extensions to classify as the type, ahead of the built-in lists
+		if ft, ok := configType(name, path, lineNo); ok && ft != EXECUTABLE && ft != DEFAULT {
+			for _, ext := range configExtensions(value) {
+				extensionTypes[ext] = ft
+			}
+		} else if ok {
+			configError(path, lineNo, "unknown type %s\n", name)
+		}
+	default:
+		configError(path, lineNo, "unknown setting %s\n", kind)
+	}
+}
+
+// The type a type or color setting names.
+func configType(name string, path string, lineNo int) (Filetype, bool) {
+	ft, found := fileTypeNames[strings.ToLower(name)]
+	if !found {
+		configError(path, lineNo, "unknown type %s\n", name)
+	}
+	return ft, found
+}
+
+// The extensions in a type setting, lower case and without dots.  They may have dots inside, as pb.go.
+func configExtensions(value string) []string {
+	var extensions []string
+	for _, ext := range strings.Split(value, ",") {
+		if ext = strings.TrimPrefix(strings.TrimSpace(ext), "."); len(ext) > 0 {
+			extensions = append(extensions, strings.ToLower(ext))
+		}
+	}
+	return extensions
+}
+
+// Renders a configured column for a file.  The template sees a *fileitem, so .Name,
+// .Size, .Modified, .Extension and so on are all available.
+func (f fileitem) CustomColumn(tmpl *template.Template) string {
+	var out strings.Builder
+	if err := tmpl.Execute(&out, &f); err != nil {
+		conditionalPrint(show_errors, "Column %s failed on %s: %s\n", tmpl.Name(), f.Name, err.Error())
+		return "?"
+	}
+	return out.String()
+}
+
+// Splits a string on whitespace, honoring single and double quotes.
+func splitArgs(s string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// Replaces -name (or a leading bare name, like a subcommand) with the alias's arguments.
+func expandAliases(args []string, depth int) []string {
+	if len(aliases) == 0 {
+		return args
+	}
+	var expanded []string
+	changed := false
+	for i, a := range args {
+		name := ""
+		if strings.HasPrefix(a, "-") && !strings.Contains(a, "=") {
+			name = a[1:]
+		} else if i == 0 {
+			if _, err := os.Stat(a); err != nil {
+				name = a
+			}
+		}
+		if value, found := aliases[name]; found && len(name) > 0 {
+			conditionalPrint(debug_messages, "Alias %s expanded to %v\n", a, value)
+			expanded = append(expanded, value...)
+			changed = true
+		} else {
+			expanded = append(expanded, a)
+		}
+	}
+	if changed && depth < maxAliasDepth {
+		return expandAliases(expanded, depth+1)
+	}
+	return expanded
+}
@@ -16,15 +16,11 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 import (
-	"archive/tar"
-	"archive/zip"
-	"bufio"
-	"bytes"
-	"compress/gzip"
+	"cmp"
 	_ "embed"
 	"errors"
 	"fmt"
-	"io/fs"
+	"io"
 	"math"
 	"os"
 	"os/exec"
@@ -34,9 +30,10 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/bodgit/sevenzip"
 	"github.com/gobwas/glob"
 )
 
@@ -48,20 +45,57 @@ import (
 //go:embed dirhelp.txt
 var helptext string
 
+const searchOverlap = 4096 // Tail of each window searched again with the next, for matches across the boundary
+
 const versionDate = "2024-02-08"
 
 const (
 	COLUMN_DATEMODIFIED = "m"
 	COLUMN_DATECREATED  = "c"
 	COLUMN_DATEACCESSED = "a"
+	COLUMN_DATECHANGED  = "i" // Inode change time, ctime
 	COLUMN_FILESIZE     = "s"
 	COLUMN_MODE         = "p" // for permissions
 	COLUMN_NAME         = "n" // filename
 	COLUMN_LINK         = "l" // e.g. symlink target
+	COLUMN_VERSION      = "v" // Windows PE FileVersion/ProductVersion, opt-in
+	COLUMN_BINARY       = "h" // Executable header: format, arch, stripped. Opt-in
+	COLUMN_SIGNATURE    = "t" // Trust: code signature status (macOS/Windows). Opt-in
+	COLUMN_CHECKSUM     = "k" // OK/FAIL against sidecar checksum files
+	COLUMN_CHANGE       = "x" // + (added) or M (modified) relative to -since
+	COLUMN_OWNER        = "o"
+	COLUMN_LINKS        = "L" // Hard link count
+	COLUMN_FILESYSTEM   = "f" // File system type
+	COLUMN_ORIGIN       = "O" // -trashcan: original location
+	COLUMN_DELETED      = "D" // -trashcan: deletion time
+	COLUMN_GROUP        = "g"
+	COLUMN_MATCHES      = "H" // Content search hits
+	COLUMN_EFFECTIVE    = "e" // What the current user can do: rwx
+	COLUMN_COMPRESSED   = "z" // Archive members: size within the archive
+	COLUMN_MEMBERS      = "u" // Archives: number of files and their total size
+	COLUMN_PATTERNS     = "P" // -tf patterns found
+	COLUMN_CHAIN        = "r" // Symlinks followed to the end
+	COLUMN_AGE          = "d" // Days since modified, or as -age=
+	COLUMN_CONTAINER    = "w" // Archive members: the archive they're in
+	COLUMN_MEMBER       = "b" // Archive members: the path within it
+	COLUMN_SHARED       = "C" // -clones: bytes shared with other files
+	COLUMN_ROW          = "#" // -number: the file's number in the listing
+	COLUMN_OFFICE       = "q" // Office documents: sheets, slides or pages.  Opt-in
+	COLUMN_LANGUAGE     = "y" // Programming language, from the extension or #! line
+	COLUMN_SENSITIVE    = "!" // Likely holds secrets, by name: key, env, creds...
 )
 
+// All of the above, so configured columns don't collide with them.
+const builtinColumns = COLUMN_DATEMODIFIED + COLUMN_DATECREATED + COLUMN_DATEACCESSED + COLUMN_FILESIZE + COLUMN_MODE +
+	COLUMN_NAME + COLUMN_LINK + COLUMN_VERSION + COLUMN_BINARY + COLUMN_SIGNATURE + COLUMN_CHECKSUM + COLUMN_CHANGE +
+	COLUMN_OWNER + COLUMN_GROUP + COLUMN_MATCHES + COLUMN_LINKS +
+	COLUMN_ORIGIN + COLUMN_DELETED + COLUMN_FILESYSTEM + COLUMN_EFFECTIVE + COLUMN_COMPRESSED + COLUMN_MEMBERS + COLUMN_PATTERNS + COLUMN_CHAIN + COLUMN_AGE +
+	COLUMN_CONTAINER + COLUMN_MEMBER + COLUMN_SHARED + COLUMN_DATECHANGED + COLUMN_ROW + COLUMN_OFFICE + COLUMN_LANGUAGE + COLUMN_SENSITIVE
+
 var columnDef = "p   m  (c)  s   nl" // See above. Spaces and parens, etc, are relevant.
 
+const lsColumns = "p L o g s m nl" // -l, like ls -l
+
 type sortfield string
 type sortorder struct {
 	field     sortfield
@@ -71,7 +105,6 @@ type sizeformat int
 type Attributes string
 type InclusionMod string
 type searchtype int
-type ArchiveType int
 type Filetype int
 
 const (
@@ -79,10 +112,16 @@ const (
 	SORT_DATE         sortfield  = "d" // Sort by last modified. (Which is "m" in columns)
 	SORT_CREATED      sortfield  = COLUMN_DATECREATED
 	SORT_ACCESSED     sortfield  = COLUMN_DATEACCESSED
+	SORT_CHANGED      sortfield  = COLUMN_DATECHANGED
 	SORT_SIZE         sortfield  = "s"
+	SORT_COMPRESSED   sortfield  = "z" // Size within the archive
 	SORT_TYPE         sortfield  = "e" // Uses mod and knowledge of extensions to group, e.g. image, archive, code, document
 	SORT_EXT          sortfield  = "x" // Extension in DOS
 	SORT_NATURAL      sortfield  = "o" // Don't sort
+	SORT_PATH         sortfield  = "p" // Path, then name.  Used as a tiebreaker
+	SORT_OWNER        sortfield  = "w" // Owner, then group
+	SORT_RELEVANCE    sortfield  = "r" // Content search match count
+	SORT_TREE         sortfield  = "u" // Directories by everything in them, as du does
 	SIZE_NATURAL      sizeformat = 0   // Sizes as unformatted bytes
 	SIZE_SEPARATOR    sizeformat = 1   // Sizes formatted with localconv non-monetary separator
 	SIZE_QUANTA       sizeformat = 2   // Sizes formatted with units/quanta - e.g. GB, TB...
@@ -90,11 +129,14 @@ const (
 	SEARCH_CASE       searchtype = 1
 	SEARCH_NOCASE     searchtype = 2
 	SEARCH_REGEX      searchtype = 3 // Technically, all but none become REGEX, with NOCASE being modified.
+	SEARCH_HEX        searchtype = 4 // Bytes, with hex_pattern instead of text_regex
 	PROGRAM_NOT_FOUND            = "program not found"
-	ARCHIVE_NA                   = iota
-	ARCHIVE_ZIP
-	ARCHIVE_TGZ
-	ARCHIVE_7Z
+)
+const (
+	// What the current user can do, from effectiveAccess.  The same bits as access(2).
+	ACCESS_READ    = 4
+	ACCESS_WRITE   = 2
+	ACCESS_EXECUTE = 1
 )
 const (
 	// Filetypes
@@ -129,6 +171,27 @@ var Extensions = map[Filetype]string{
 	CODE:     ",ahk,applescript,asm,au3,bas,bash,bat,c,cmake,cmd,coffee,cpp,cs,cxx,dockerfile,elf,es,exe,go,gradle,groovy,gvy,h,hpp,hxx,inc,ino,java,js,kt,ktm,kts,lua,m,mak,mm,perl,ph,php,pl,pp,ps1,psm1,py,rake,rb,rbw,rbuild,rbx,rs,ru,ruby,scpt,sh,ts,tsx,v,vb,vbs,vhd,vhdl,zsh,",
 }
 
+// Lower-case extension to its type, from Extensions and any "type" config settings.  Looking
+// one up is much quicker than searching each list, which adds up over many files.
+var extensionTypes = buildExtensionTypes()
+
+// Names for the types, for -tt and the config file.
+var fileTypeNames = map[string]Filetype{"audio": AUDIO, "archive": ARCHIVE, "image": IMAGE, "video": IMAGE, "document": DOCUMENT,
+	"data": DATA, "config": CONFIG, "code": CODE, "executable": EXECUTABLE, "other": DEFAULT}
+
+// Builds extensionTypes.  An extension in two lists goes with the first type, as FileType() did.
+func buildExtensionTypes() map[string]Filetype {
+	types := map[string]Filetype{}
+	for ft := AUDIO; ft <= CODE; ft++ {
+		for _, ext := range strings.Split(strings.Trim(Extensions[ft], ","), ",") {
+			if _, found := types[strings.ToLower(ext)]; !found {
+				types[strings.ToLower(ext)] = ft
+			}
+		}
+	}
+	return types
+}
+
 // Could use a slice here, since it's indexing in by int, but naming the spots makes it clearer.
 var FileTypeSortOrder = map[Filetype]int{DIRECTORY: 0, HIDDEN: 1, NONE: 2, DEFAULT: 3, CODE: 4, EXECUTABLE: 5, CONFIG: 6,
 	DATA: 7, DOCUMENT: 8, AUDIO: 9, IMAGE: 10, ARCHIVE: 11}
@@ -150,37 +213,77 @@ var ( // Runtime configuration
 	debug_messages                = false
 	bare                bool      = false // Only print filenames
 	include_path                  = false // Turn on in bare+ mode
+	relative_paths                = false // Paths relative to start_directory, for rsync --files-from
 	sortby                        = sortorder{SORT_NAME, true}
+	sort_tiebreak                 = SORT_NAME // For files equal on sortby
 	directories_first             = true
 	listdirectories     bool      = true
 	listfiles           bool      = true
 	listInArchives      bool      = false
+	archives_top_only   bool      = false   // -z=top: only archives in the start directory, not ones found recursing
+	archive_order                 = "first" // -archive-order: archives before subdirectories, after them, or mixed by name
 	listhidden          bool      = true
-	directory_header    bool      = true // Print name of directory.  Usually with size_calculations
+	only_hidden         bool      = false // DOS /a:h
+	archives_only       bool      = false // -za: archive files themselves, no members
+	readonly_filter     int       = 0     // 1 for only read-only files, -1 for only writable
+	access_required     int       = 0     // ACCESS_ bits the current user must have, for -readable etc.
+	access_refused      int       = 0     // ACCESS_ bits they must not have, for -readable- etc.
+	totals_only         bool      = false // Headers and summaries only, no file rows
+	directory_header    bool      = true  // Print name of directory.  Usually with size_calculations
 	pathIsArchive       bool      = false
 	size_calculations   bool      = true // Print directory byte totals
 	recurse_directories bool      = false
-	mindate             time.Time // Filter for min/max date, requires minmaxdatetype
+	max_depth                     = -1 // -depth=: levels below the start directory -r goes, or -1 for all
+	dirDepth                      = 0  // Levels below the start directory of the one being listed
+	mindate             time.Time      // Filter for min/max date, requires minmaxdatetype
 	maxdate             time.Time
-	minmaxdatetype      string = "m" // May be m = modified, a = accessed, c = created. Only one is allowed.
+	minmaxdatetype      string = "m" // May be m = modified, a = accessed, c = created, i = changed. Only one is allowed.
+	age_from            string = "m" // The time the age column counts from: m, c or a
+	time_precision      string = "s" // Or ms, us or ns
 	minsize             int64  = -1
 	maxsize             int64  = math.MaxInt64
-	matcher             glob.Glob
+	min_compressed      int64  = -1 // -mz, against CompressedSize()
+	max_compressed      int64  = math.MaxInt64
+	min_entries         int64  = -1 // -mine and -maxe, on the entries in directories
+	max_entries         int64  = math.MaxInt64
+	min_links           int64  = -1 // -links-count, on files' hard link counts
+	max_links           int64  = math.MaxInt64
+	prefix_strip        string // Taken off the start of paths shown
+	prefix_add          string // And put on instead
 	start_directory     string
-	file_mask           string
-	filenameParsed      bool       = false
+	file_masks          []string
 	haveGlobber                    = false
-	case_sensitive      bool       = false
-	exclude_exts        []string   // Upper-case list of extensions to ignore.
+	case_sensitive      bool       = false // File mask matching
+	case_sensitive_sort bool       = false // Name collation when sorting
+	exclude_exts        []string           // Upper-case list of extensions to ignore.
+	exclude_dirs        []string           // -xd: directory names or globs not listed or gone into
+	search_types        []Filetype         // -tt: content search only opens these types...
+	search_exts         []string           // ...and these upper-case extensions
 	filesizes_format    sizeformat = SIZE_NATURAL
 	use_colors          bool       = false
 	use_enhanced_colors bool       = true // only applies if use_colors is on.
 	text_search_type    searchtype = SEARCH_NONE
 	text_regex          *regexp.Regexp
-	PdftotextPath       string = "*" // Uninitialized
+	search_text         string            // From -tc, -ti, -ts or -tr; compiled into text_regex by buildTextSearch
+	smart_case          bool   = false    // -ts
+	whole_word          bool   = false    // -tw
+	multiline           bool   = false    // -tm: whole files searched at once, . matching newlines
+	multiline_max       int64  = 64 << 20 // Largest file -tm reads whole; larger ones are searched a window at a time
+	search_window       int    = 1 << 20  // Bytes of a file searched at a time, and the longest match carried between them
+	count_matches       bool   = false    // Count every match, not just the first.  For sorting by relevance
+	owner_needed        bool   = false    // Look up owner and group names
+	unordered           bool   = false    // Skip sorting, and list search matches as they are found
+	ls_dates            bool   = false    // Dates like ls -l
+	ls_style            bool   = false    // -l
+	PdfHelperPath       string            // Set on first use, by pdfHelperOnce
+	pdfHelperInUse      *pdfHelper
+	pdfHelperOnce       sync.Once
 	TotalFiles          int
 	TotalBytes          int64
+	unreadableArchives  int    // Damaged or truncated, with -z
 	ColumnOrder         string = ""
+	verify_sidecars     bool   = false // Adds the checksum column
+	field_separator     string = ""    // If set, joins columns instead of columnDef's literal text
 )
 
 func ternaryString(condition bool, s1 string, s2 string) string {
@@ -190,6 +293,13 @@ func ternaryString(condition bool, s1 string, s2 string) string {
 	return s2
 }
 
+func ternaryInt(condition bool, i1 int, i2 int) int {
+	if condition {
+		return i1
+	}
+	return i2
+}
+
 /******* HANDLING COLORS *******/
 /* General description of the LS_COLORS format:  It is a two-letter index and up to three digits separated by semicolons.
    Style;foreground color; background color.  They occupy different numeric spaces.
@@ -266,7 +376,40 @@ func resolveCommand(cmd string) string {
 }
 
 // Does this file meet current conditions for inclusion?
-func fileMeetsConditions(target fileitem) bool {
+// For content searches, this also fills in target.Matches.
+func fileMeetsConditions(target *fileitem) bool {
+	return fileMeetsFilters(target) && fileMeetsTextSearch(target) && snapshotConditions(*target)
+}
+
+var exclude_matchers []glob.Glob // One for each of exclude_dirs, compiled once -cs is known
+
+// Whether -xd= leaves the directory out.  Names are globs, matched like the file mask, so
+// case-insensitive unless -cs.
+func excludedDirectory(name string) bool {
+	name = ternaryString(case_sensitive, name, strings.ToUpper(name))
+	for _, m := range exclude_matchers {
+		if m.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+var matchers []glob.Glob // One for each of file_masks, compiled once -cs is known
+
+// Whether the name matches any of the file masks.  Names are upper-cased for them unless -cs.
+func maskMatches(name string) bool {
+	name = ternaryString(case_sensitive, name, strings.ToUpper(name))
+	for _, m := range matchers {
+		if m.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// The quick conditions: type, visibility, dates, size and name.
+func fileMeetsFilters(target *fileitem) bool {
 	if (!listdirectories) && target.IsDir {
 		return false
 	}
@@ -281,6 +424,32 @@ func fileMeetsConditions(target fileitem) bool {
 	if (!listhidden) && filename[0] == '.' {
 		return false
 	}
+	if trashInfo != nil && isTrashBookkeeping(target) {
+		return false
+	}
+	if only_hidden && filename[0] != '.' {
+		return false
+	}
+	if linkto_matcher != nil && !linkTargetMatches(target) {
+		return false
+	}
+	if archives_only && (target.IsDir || target.InArchive || !target.IsArchive()) {
+		return false
+	}
+	if readonly_filter != 0 && (target.Mode&0222 == 0) != (readonly_filter > 0) {
+		return false
+	}
+	for _, filter := range perm_filters {
+		if !filter.matches(target.Mode) {
+			return false
+		}
+	}
+	if access_required|access_refused != 0 {
+		access := target.EffectiveAccess()
+		if access&access_required != access_required || access&access_refused != 0 {
+			return false
+		}
+	}
 
 	// Check date ranges - there are three possibilities
 	if !mindate.IsZero() {
@@ -290,8 +459,11 @@ func fileMeetsConditions(target fileitem) bool {
 		if minmaxdatetype == "c" && target.Created.Before(mindate) {
 			return false
 		}
+		if minmaxdatetype == "i" && target.Changed.Before(mindate) {
+			return false
+		}
 		// Else a
-		if target.Accessed.Before(mindate) {
+		if minmaxdatetype == "a" && target.Accessed.Before(mindate) {
 			return false
 		}
 	}
@@ -303,67 +475,136 @@ func fileMeetsConditions(target fileitem) bool {
 		if minmaxdatetype == "c" && target.Created.After(maxdate) {
 			return false
 		}
+		if minmaxdatetype == "i" && target.Changed.After(maxdate) {
+			return false
+		}
 		// Default a
-		if target.Accessed.After(maxdate) {
+		if minmaxdatetype == "a" && target.Accessed.After(maxdate) {
 			return false
 		}
 	}
 	if target.Size < minsize || target.Size > maxsize {
 		return false
 	}
+	if linkCountFilter() && (target.IsDir || target.InArchive || int64(target.Links) < min_links || int64(target.Links) > max_links) {
+		return false
+	}
+	if min_compressed >= 0 || max_compressed < math.MaxInt64 {
+		if c := target.CompressedSize(); c < 0 || c < min_compressed || c > max_compressed {
+			return false
+		}
+	}
 
 	// If we don't have the globber, return true.  Otherwise match it.
-	if haveGlobber {
-		testString := ternaryString(case_sensitive, filename, strings.ToUpper(filename))
-		if !matcher.Match(testString) {
+	if haveGlobber && !maskMatches(filename) {
+		return false
+	}
+	// Last, as it reads the directory
+	if target.IsDir && (min_entries >= 0 || max_entries < math.MaxInt64) {
+		if n := target.EntryCount(); n < 0 || n < min_entries || n > max_entries {
 			return false
 		}
 	}
+	if sensitive_only && len(target.SensitiveKind()) == 0 {
+		return false
+	}
+	if skip_generated && isGenerated(target) {
+		return false
+	}
+	if len(languages) > 0 && !languageMatches(target) {
+		return false
+	}
+	if member_matcher != nil && !archiveHasMember(filepath.Join(target.Path, target.Name)) {
+		return false
+	}
+	return true
+}
 
+// The content search, if there is one.  Fills in target.Matches.  This is the slow part,
+// so searchFiles runs it on several files at once.
+func fileMeetsTextSearch(target *fileitem) bool {
 	t_ext := target.Extension()
 	if text_search_type != SEARCH_NONE {
 		if target.IsDir {
 			return false
 		}
-		if target.InArchive {
-			if !archiveFileTextSearch(target) {
-				return false
-			}
-		} else if t_ext == "DOCX" || t_ext == "PPTX" || t_ext == "XLSX" || t_ext == "VSDX" {
-			conditionalPrint(debug_messages, "Embedded Zip text search on %s.\n", target.Name)
-			embeddedFiles, err := filesInZipArchive(filepath.Join(target.Path, target.Name), false)
-			if err != nil {
-				conditionalPrint(show_errors, "Could not unzip %s: %s\n", target.Name, err.Error())
-				return false
-			}
-			found := false
-			for _, f := range embeddedFiles.MatchedFiles {
-				var data []byte
-				data, err = extractZipFileBytes(f.Path, f.Name, 0, int(f.Size))
-				found = text_regex.Match(data)
-				if found {
-					break
-				}
-			}
-			if err != nil { // Try brute forcè
-				found = diskFileTextSearch(target)
-			}
-			if !found {
-				return false
-			}
-			// We want to fall through to brute-force on any error.  Error may be PROGRAM_NOT_FOUND
-		} else if s, e := PDFText(filepath.Join(target.Path, target.Name), false); e == nil {
-			if !text_regex.Match([]byte(s)) {
-				return false
+		if !searchableType(target) {
+			searchStats.skippedType.Add(1)
+			return false
+		}
+		searchStats.scanned.Add(1)
+		searchStats.scannedBytes.Add(target.Size)
+		if text_search_type == SEARCH_HEX { // Raw bytes, never extracted text
+			if !target.InArchive {
+				target.Matches = diskFileTextSearch(target)
+			} else if target.Size > maxArchiveMemberBytes {
+				searchStats.tooLarge.Add(1)
+			} else if data, err := archiveMemberBytes(*target); err == nil {
+				target.Matches = countMatches(target, data)
+			} else {
+				searchStats.unreadable.Add(1)
 			}
-		} else if !diskFileTextSearch(target) {
+		} else if target.InArchive {
+			target.Matches = archiveFileTextSearch(target)
+		} else if extractor := extractorFor(t_ext); extractor == nil {
+			target.Matches = diskFileTextSearch(target)
+		} else if text, err := extractor.FileText(filepath.Join(target.Path, target.Name)); err == nil {
+			target.Matches = countMatches(target, text)
+		} else if err != errHelperTimeout { // Try brute force.  The error may be PROGRAM_NOT_FOUND
+			conditionalPrint(debug_messages, "Could not extract the text of %s: %s\n", target.Name, err.Error())
+			target.Matches = diskFileTextSearch(target)
+		} else {
+			searchStats.timedOut.Add(1)
+		}
+		if target.Matches == 0 {
 			return false
 		}
+		searchStats.matched.Add(1)
+		searchStats.matchedBytes.Add(target.Size)
+		searchStats.matches.Add(int64(target.Matches))
 	}
-
 	return true
 }
 
+// Whether -tt allows opening the file for content search: one of its types, by classification
+// or extension, or one of its extensions.
+func searchableType(target *fileitem) bool {
+	if len(search_types) == 0 && len(search_exts) == 0 {
+		return true
+	}
+	ext := target.Extension()
+	if slices.Contains(search_exts, ext) {
+		return true
+	}
+	for _, ft := range search_types {
+		if target.FileType() == ft || (len(ext) > 0 && extensionTypes[strings.ToLower(ext)] == ft) {
+			return true
+		}
+	}
+	return false
+}
+
+// Number of matches of text_regex in data.  Stops at 1 unless count_matches.
+func countMatches(target *fileitem, data []byte) int {
+	searchStats.bytesRead.Add(int64(len(data)))
+	if !count_matches && hex_pattern == nil {
+		return ternaryInt(text_regex.Match(data), 1, 0)
+	}
+	found := findMatches(data, ternaryInt(count_matches, -1, 1))
+	for _, m := range found {
+		target.notePattern(data[m[0]:m[1]])
+	}
+	return len(found)
+}
+
+// Where the search matches in data, like regexp's FindAllIndex: up to n, or all if n < 0.
+func findMatches(data []byte, n int) [][]int {
+	if hex_pattern != nil {
+		return hexMatchIndexes(data, n)
+	}
+	return text_regex.FindAllIndex(data, n)
+}
+
 // Returns an error if not opened or no utility (pdftotext)
 func PDFText(filepath string, ignoreExtension bool) (string, error) {
 	// Due to limitations of Go, I'm doing a fitness check here.
@@ -372,130 +613,166 @@ func PDFText(filepath string, ignoreExtension bool) (string, error) {
 		return "", errors.New("not a pdf file")
 	}
 
-	// Have we already checked?
-	if PdftotextPath == "" {
+	// Find the helper the first time.  Searches run in parallel, so only once.
+	pdfHelperOnce.Do(func() {
+		PdfHelperPath, pdfHelperInUse = resolvePDFHelper()
+		if len(PdfHelperPath) == 0 {
+			conditionalPrint(debug_messages, "Could not find pdftotext or another PDF helper.  PDF text will not be found.\n")
+		}
+	})
+	if PdfHelperPath == "" {
 		return "", errors.New(PROGRAM_NOT_FOUND)
 	}
-	// Or do we need to initialize this value?
-	if PdftotextPath == "*" {
-		PdftotextPath = resolveCommand("pdftotext")
-		if len(PdftotextPath) == 0 {
-			conditionalPrint(debug_messages, "Could not find pdftotext.  PDF text will not be found.\n")
-			return "", errors.New(PROGRAM_NOT_FOUND)
-		}
+	stdout, err := pdfHelperInUse.text(PdfHelperPath, filepath)
+	if err == errHelperTimeout {
+		return "", err
+	} else if err != nil {
+		conditionalPrint(debug_messages, "Could not run "+PdfHelperPath+" on "+filepath+"; "+err.Error()+"\n")
+		return "", errors.New("could not run " + PdfHelperPath + " on " + filepath + "; " + err.Error())
 	}
-	// pdftotext uses - to send output to stdout.
-	cmd := exec.Command(PdftotextPath, filepath, "-")
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
+	return string(stdout), err
+}
+
+// Load and search one file in the zip, with a maximum size.  Returns the number of matches.
+func archiveFileTextSearch(target *fileitem) int {
+	if target.Size > maxArchiveMemberBytes {
+		searchStats.tooLarge.Add(1)
+		return 0
+	}
+	data, err := archiveMemberBytes(*target)
 	if err != nil {
-		conditionalPrint(debug_messages, "Could not run pdftotext on "+filepath+"; "+err.Error()+"\n")
-		return "", errors.New("could not run pdftotext on " + filepath + "; " + err.Error())
+		searchStats.unreadable.Add(1)
+		return 0
 	}
-	if stderr.Len() > 0 {
-		fmt.Printf("Got errors: %s", stderr.String())
+	return memberTextSearch(target, target.Name, data, 0)
+}
+
+// Searches the data of an archive member called name, noting matches on target - the member, or
+// the member an archive holding it is in.  Office documents, PDFs and archives in archives are
+// opened, up to -archive-depth deep.
+func memberTextSearch(target *fileitem, name string, data []byte, depth int) int {
+	if depth < archive_max_depth {
+		if extractor := extractorFor(fileitem{Name: name}.Extension()); extractor != nil {
+			if text, err := extractor.MemberText(name, data); err == nil {
+				return countMatches(target, text)
+			}
+		} else if handler := archiveHandlerFor(name); handler != nil {
+			if matches, err := nestedArchiveTextSearch(target, name, data, depth+1); err == nil {
+				return matches
+			}
+		}
 	}
-	return stdout.String(), err
+	return countMatches(target, data)
 }
 
-// Load and search one file in the zip, with a maximum size.
-func archiveFileTextSearch(target fileitem) bool {
-	var data []byte
-	var err error
-	if target.Size > 1000000 {
-		return false
+// Searches the members of an archive inside another.  Handlers read files, so it's copied out
+// first.  The archive guards apply to it as to any other.
+func nestedArchiveTextSearch(target *fileitem, name string, data []byte, depth int) (int, error) {
+	afile, err := os.CreateTemp("", "*-"+filepath.Base(name))
+	if err != nil {
+		return 0, err
 	}
-	switch FileIsArchiveType(target.Path) {
-	case ARCHIVE_ZIP:
-		data, err = extractZipFileBytes(target.Path, target.Name, 0, int(target.Size))
-	case ARCHIVE_7Z:
-		data, err = extract7ZFileBytes(target.Path, target.Name, 0, int(target.Size))
-	case ARCHIVE_TGZ:
-		data, err = extractTgzFileBytes(target.Path, target.Name, 0, int(target.Size))
-	default:
-		// No handler found.
-		return false
+	afilename := afile.Name()
+	defer os.Remove(afilename)
+	_, err = afile.Write(data)
+	afile.Close()
+	if err != nil {
+		return 0, err
 	}
+	handler := archiveHandlerFor(afilename)
+	members, err := handler.List(afilename, func(item *fileitem) bool { return !item.IsDir })
+	if err != nil && len(members) == 0 {
+		return 0, err
+	}
+	searchStats.archives.Add(1)
+	matches := 0
+	for _, member := range members {
+		if member.Size > maxArchiveMemberBytes {
+			searchStats.tooLarge.Add(1)
+			continue
+		}
+		memberData, err := readMember(handler, afilename, member.Name, member.Size)
+		if err != nil {
+			searchStats.unreadable.Add(1)
+			continue
+		}
+		matches += memberTextSearch(target, member.Name, memberData, depth)
+		if matches > 0 && !count_matches {
+			break // One is enough
+		}
+	}
+	return matches, nil
+}
+
+// For -tm: reads the file whole, so a match can run across any number of lines.
+func wholeFileTextSearch(target *fileitem) int {
+	data, err := os.ReadFile(filepath.Join(target.Path, target.Name))
 	if err != nil {
-		return false
+		conditionalPrint(show_errors, "Could not read file for text search: %s - %s\n", target.Name, err.Error())
+		searchStats.unreadable.Add(1)
+		return 0
 	}
-	var t_ext string = target.Extension()
-	if t_ext == "DOCX" || t_ext == "PPTX" || t_ext == "XLSX" || t_ext == "VSDX" || t_ext == "PDF" {
-		// Write to a temp file so we can more easily uncompress the docx or run a util on the PDF
-		var err error
-		var pfile *os.File
-		pfile, err = os.CreateTemp("", target.Name)
-		if err == nil {
-			pfilename := pfile.Name()
-			pfile.Write(data)
-			pfile.Close()
-			defer os.Remove(pfilename)
-			data = nil
-			if t_ext == "PDF" {
-				s, e := PDFText(pfile.Name(), true)
-				if e == nil {
-					return text_regex.Match([]byte(s))
-				}
-			} else { // Handle Office files - decompress and check
-				embeddedFiles, err := filesInZipArchive(pfile.Name(), false)
-				if err == nil {
-					for _, f := range embeddedFiles.MatchedFiles {
-						var data []byte
-						data, err = extractZipFileBytes(f.Path, f.Name, 0, int(f.Size))
-						if err == nil {
-							if text_regex.Match(data) {
-								return true
-							}
-						}
-					}
-				}
-			}
-		} // temp file creation success
-	} // office or pdf file
-	return text_regex.Match(data)
+	return countMatches(target, data)
 }
 
-// Searches the file in chunks.
-// Returns true if the file has the text.  False on error or not found.
-func diskFileTextSearch(target fileitem) bool {
-	found_text := false
-	// Load file in blocks of 200KB for speed and memory.
+// Searches the file through a sliding window: each chunk is searched along with the last
+// searchOverlap bytes of the one before, so matches across chunk boundaries are found if they
+// start within them.  A match that runs to the end of a chunk might go on into the next, so it is
+// carried forward whole, up to the window size, and counted once the data after it has been seen.
+// Returns the number of matches (just 1 unless count_matches.)  0 on error or not found.
+func diskFileTextSearch(target *fileitem) int {
+	if multiline && target.Size <= multiline_max {
+		return wholeFileTextSearch(target)
+	}
+	matches := 0
 	file, err := os.Open(filepath.Join(target.Path, target.Name))
 	if err != nil {
 		conditionalPrint(show_errors, "Could not open file for text search: %s - %s\n", target.Name, err.Error())
-		return false
+		searchStats.unreadable.Add(1)
+		return 0
 	}
 	defer file.Close()
-	reader := bufio.NewReader(file)
-	// Any "Go" purist who thought generics are a bad idea... would fail an interview at any productive company.
-	// Min() and Max() should not be this hard.  I understand the philosophy, but those philosophers are idiots
-	// who don't deserve paying jobs.
-	chunkSize := 20000
-	overlapSize := 400
-	if chunkSize > int(target.Size) {
-		chunkSize = int(target.Size)
-		overlapSize = 0
+	window := search_window
+	if window > int(target.Size) && target.Size > 0 {
+		window = int(target.Size) // Small files in one read
 	}
-
-	searchBuffer := make([]byte, chunkSize+overlapSize)
-
-	for !found_text {
-		n, err := reader.Read(searchBuffer[overlapSize:])
-
-		if err != nil && err.Error() != "EOF" {
-			conditionalPrint(show_errors, "Could not open file for text search: %s - %s\n", target.Name, err.Error())
-			return false
+	overlap := searchOverlap
+	if overlap > window {
+		overlap = window
+	}
+	buffer := make([]byte, 0, 2*window)
+	for {
+		carried := len(buffer)
+		n, err := io.ReadFull(file, buffer[carried:carried+window])
+		buffer = buffer[:carried+n]
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			conditionalPrint(show_errors, "Could not read file for text search: %s - %s\n", target.Name, err.Error())
+			searchStats.unreadable.Add(1)
+			return 0
 		}
-		found_text = text_regex.Match(searchBuffer)
-
-		// Check for EOF
-		if (n < chunkSize) || n == int(target.Size) {
+		searchStats.bytesRead.Add(int64(n))
+		keepFrom := len(buffer) - overlap
+		if keepFrom < 0 {
+			keepFrom = 0
+		}
+		for _, m := range findMatches(buffer, ternaryInt(count_matches, -1, 1)) {
+			if !final && m[1] == len(buffer) && len(buffer)-m[0] <= window {
+				keepFrom = m[0] // It may continue; count it next time round
+				break
+			}
+			matches++
+			target.notePattern(buffer[m[0]:m[1]])
+			if m[1] > keepFrom {
+				keepFrom = m[1]
+			}
+		}
+		if final || (matches > 0 && !count_matches) || outOfTime() {
 			break
 		}
+		buffer = buffer[:copy(buffer, buffer[keepFrom:])]
 	}
-	return found_text
+	return matches
 }
 
 type ListingSet struct {
@@ -508,274 +785,264 @@ type ListingSet struct {
 	Bytesfound     int64
 }
 
-func extractZipFileBytes(zippath string, filename string, offset int, length int) ([]byte, error) {
-	var buffer = make([]byte, length)
-	zipReader, err := zip.OpenReader(zippath)
-	if err != nil {
-		if show_errors {
-			fmt.Printf("Error: Could not open %s.  %s\n", filename, err.Error())
+// An archive or sub directory to list after the files of the directory holding it.
+type descent struct {
+	name    string
+	archive bool
+}
+
+// The archives and sub directories to go into next, in -archive-order.  The same rules apply at
+// every level of -r, except that -z=top leaves archives below the start directory alone.
+func descentOrder(ls ListingSet, recursed bool) []descent {
+	var archives, subdirs []descent
+	if listInArchives && !(archives_top_only && recursed) {
+		for _, a := range ls.Archives {
+			archives = append(archives, descent{a, true})
 		}
-		return nil, err
 	}
-	defer zipReader.Close()
-
-	for _, fileInZip := range zipReader.File {
-		if fileInZip.Name != filename {
-			continue
+	if recurse_directories && (max_depth < 0 || dirDepth < max_depth) {
+		for _, d := range ls.Subdirs {
+			subdirs = append(subdirs, descent{d, false})
 		}
-		readCloser, err := fileInZip.Open()
-		if err != nil {
-			return nil, err
-		}
-		defer readCloser.Close()
-		// Pseudo-seek - read buffer size until we get there.
-		curPos := 0
-		for curPos < offset {
-			readAmount := length
-			if readAmount+curPos > offset {
-				readAmount = offset - curPos
-				newBuf := make([]byte, readAmount)
-				readCloser.Read(newBuf)
-			} else {
-				readCloser.Read(buffer)
-			}
-			curPos += length
+	}
+	byName := func(d []descent) {
+		if !unordered {
+			sort.SliceStable(d, func(i, j int) bool { return d[i].name < d[j].name })
 		}
-		// Pseudo-Seek done.  Uggah.
-		readCloser.Read(buffer)
-		break
 	}
-	return buffer, err
+	byName(archives)
+	byName(subdirs)
+	switch archive_order {
+	case "last":
+		return append(subdirs, archives...)
+	case "mixed":
+		all := append(archives, subdirs...)
+		byName(all)
+		return all
+	}
+	return append(archives, subdirs...)
 }
 
-func extract7ZFileBytes(zippath string, filename string, offset int, length int) ([]byte, error) {
-	zipReader, err := sevenzip.OpenReader(zippath)
-	if err != nil {
-		if show_errors {
-			fmt.Printf("Error: Could not open %s.  %s\n", filename, err.Error())
+func filesInDirectory(target string) ListingSet {
+	var ls ListingSet
+	// Only what matches, and the directories and archives to go into, are kept as it's read.
+	keep := func(fi *fileitem) bool {
+		if len(ignoreRules) > 0 && ignoredByDirignore(target, fi.Name, fi.IsDir) {
+			return false
+		}
+		if fi.IsDir && excludedDirectory(fi.Name) {
+			return false
 		}
-		return nil, err
+		if len(treeConfigs) > 0 {
+			applyTreeConfig(fi)
+		}
+		fi._matched = fileMeetsFilters(fi)
+		return fi._matched || fi.IsDir || fi.IsArchive()
 	}
-	var buffer = make([]byte, length)
-
-	for _, fileInZip := range zipReader.File {
-		if fileInZip.Name != filename {
-			continue
+	files, err := source.ListDir(target, keep)
+	// Iterate through all files, matching and then sort
+	if err == nil {
+		var candidates []fileitem
+		for _, fi := range files {
+			if fi._matched {
+				candidates = append(candidates, fi)
+			}
+			// Must be outside of fileMeetsConditions().  Note we cannot use
+			// filetype, because archives may be executable.
+			if fi.IsArchive() && listInArchives {
+				ls.Archives = append(ls.Archives, fi.Name)
+			}
+			if fi.IsDir && (listhidden || fi.Name[0] != '.') {
+				ls.Subdirs = append(ls.Subdirs, fi.Name)
+			}
 		}
-		readCloser, err := fileInZip.Open()
-		if err != nil {
-			return nil, err
-		}
-		defer readCloser.Close()
-		// Pseudo-seek - read buffer size until we get there.
-		curPos := 0
-		for curPos < offset {
-			readAmount := length
-			if readAmount+curPos > offset {
-				readAmount = offset - curPos
-				newBuf := make([]byte, readAmount)
-				readCloser.Read(newBuf)
+		for _, fi := range searchFiles(candidates) {
+			if !snapshotConditions(fi) {
+				continue
+			}
+			if clones {
+				noteClones(&fi)
+			}
+			ls.MatchedFiles = append(ls.MatchedFiles, fi)
+			if fi.IsDir {
+				ls.Directorycount++
 			} else {
-				readCloser.Read(buffer)
+				ls.Filecount++
+				if !countedBefore(fi) {
+					ls.Bytesfound += fi.Size
+				}
 			}
-			curPos += length
 		}
-		// Pseudo-Seek done.  Uggah.
-		readCloser.Read(buffer)
-		break
 	}
-	return buffer, err
+	return ls
 }
 
-func extractTgzFileBytes(zippath string, filename string, offset int, length int) ([]byte, error) {
-	var gzReader *gzip.Reader
-	var tarReader *tar.Reader
-	var buffer = make([]byte, length)
+var searchWorkers = runtime.NumCPU()
 
-	file, err := os.Open(zippath)
-	if err == nil {
-		defer file.Close()
-		gzReader, err = gzip.NewReader(file)
+// Runs the content search on several files at once.  Matches keep their order in files, so
+// the output is the same from run to run, unless -unordered, which takes them as they finish.
+func searchFiles(files []fileitem) []fileitem {
+	if text_search_type == SEARCH_NONE || len(files) == 0 {
+		return files
 	}
-	if err == nil {
-		defer gzReader.Close()
-		tarReader = tar.NewReader(gzReader)
+	found := make([]bool, len(files))
+	var finished []fileitem // In the order they finished, for -unordered
+	var lock sync.Mutex
+	var workers sync.WaitGroup
+	work := make(chan int)
+	var foundHere atomic.Int64 // Toward -max=
+	for w := 0; w < searchWorkers; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range work {
+				if outOfTime() {
+					continue // Not searched, so not listed
+				}
+				found[i] = fileMeetsTextSearch(&files[i])
+				if found[i] && max_results > 0 && resultsTaken+foundHere.Add(1) >= max_results {
+					limitReached.Store(true) // The others can stop
+				}
+				if found[i] && unordered {
+					lock.Lock()
+					finished = append(finished, files[i])
+					lock.Unlock()
+				}
+			}
+		}()
 	}
-	if err != nil {
-		if show_errors {
-			fmt.Printf("Error: Could not open %s.  %s\n", filename, err.Error())
-		}
-		return nil, err
+	for i := range files {
+		work <- i
 	}
-
-	// Locate file
-	head, err := tarReader.Next()
-	for head != nil && err == nil {
-		if head.Name != filename {
-			head, err = tarReader.Next()
-			continue
-		}
-		break
+	close(work)
+	workers.Wait()
+	if unordered {
+		return finished
 	}
-	// Seek to offset
-	curPos := 0
-	for curPos < offset {
-		readAmount := length
-		if readAmount+curPos > offset {
-			readAmount = offset - curPos
-			newBuf := make([]byte, readAmount)
-			tarReader.Read(newBuf)
-		} else {
-			tarReader.Read(buffer)
+	var matched []fileitem
+	for i := range files {
+		if found[i] {
+			matched = append(matched, files[i])
 		}
-		curPos += length
-	}
-	// Pseudo-Seek done.  Uggah.  Read data
-	tarReader.Read(buffer)
-	return buffer, err
-}
-
-func FileIsArchiveType(filename string) ArchiveType {
-	extension := strings.ToLower(filename[strings.LastIndex(filename, ".")+1:])
-	if extension == "zip" {
-		return ARCHIVE_ZIP
-	} else if extension == "tgz" || extension == "gz" {
-		return ARCHIVE_TGZ
-	} else if extension == "7z" {
-		return ARCHIVE_7Z
 	}
-	return ARCHIVE_NA
+	return matched
 }
 
-func filesInZipArchive(filename string, checkConditions bool) (ListingSet, error) {
-	var ls ListingSet
-	zipReader, err := zip.OpenReader(filename)
-	if err != nil {
-		if show_errors {
-			fmt.Printf("Error: Could not open %s.  %s\n", filename, err.Error())
+// Sorts by sortby, then by sort_tiebreak for files that are equal on it.
+func sortFiles(files []fileitem) {
+	sort.SliceStable(files, func(i, j int) bool {
+		first, second := &files[i], &files[j]
+		if !sortby.ascending {
+			first, second = second, first
 		}
-		return ls, err
-	}
-	defer zipReader.Close()
-
-	for _, fileInZip := range zipReader.File {
-		var item fileitem = fileitem{filename, fileInZip.Name, int64(fileInZip.UncompressedSize64), fileInZip.ModTime(), time.Time{}, time.Time{},
-			fileInZip.FileInfo().IsDir(), fileInZip.Mode(), "", true, NONE}
-		if !checkConditions || fileMeetsConditions(item) {
-			ls.MatchedFiles = append(ls.MatchedFiles, item)
-			if item.IsDir {
-				ls.Directorycount++
-			} else {
-				ls.Filecount++
-				ls.Bytesfound += item.Size
-			}
+		if (directories_first) && (first.IsDir != second.IsDir) {
+			return first.IsDir
 		}
-	}
-	return ls, err
+		if c := compareFiles(first, second, sortby.field); c != 0 {
+			return c < 0
+		}
+		return compareFiles(first, second, sort_tiebreak) < 0
+	})
 }
 
-func filesIn7ZArchive(filename string) (ListingSet, error) {
-	var ls ListingSet
-	zipReader, err := sevenzip.OpenReader(filename)
-	if err != nil {
-		if show_errors {
-			fmt.Printf("Error: Could not open %s.  %s\n", filename, err.Error())
+// Compares two files on one sort field: negative if first comes first, 0 if equal.
+func compareFiles(first *fileitem, second *fileitem, field sortfield) int {
+	firstName := ternaryString(case_sensitive_sort, first.Name, strings.ToUpper(first.Name))
+	secondName := ternaryString(case_sensitive_sort, second.Name, strings.ToUpper(second.Name))
+	switch field {
+	case SORT_NAME:
+		return strings.Compare(firstName, secondName)
+	case SORT_PATH:
+		if c := strings.Compare(first.Path, second.Path); c != 0 {
+			return c
 		}
-		return ls, err
-	}
-	defer zipReader.Close()
-
-	for _, fileInZip := range zipReader.File {
-		var item fileitem = fileitem{filename, fileInZip.Name, fileInZip.FileInfo().Size(),
-			fileInZip.Modified, time.Time{}, time.Time{}, fileInZip.FileInfo().IsDir(), fileInZip.Mode(), "", true, NONE}
-		if fileMeetsConditions(item) {
-			ls.MatchedFiles = append(ls.MatchedFiles, item)
-			if item.IsDir {
-				ls.Directorycount++
-			} else {
-				ls.Filecount++
-				ls.Bytesfound += item.Size
-			}
+		return strings.Compare(firstName, secondName)
+	case SORT_DATE:
+		return first.Modified.Compare(second.Modified)
+	case SORT_ACCESSED:
+		return first.Accessed.Compare(second.Accessed)
+	case SORT_CREATED:
+		return first.Created.Compare(second.Created)
+	case SORT_CHANGED:
+		return first.Changed.Compare(second.Changed)
+	case SORT_SIZE:
+		return cmp.Compare(first.Size, second.Size)
+	case SORT_COMPRESSED:
+		return cmp.Compare(first.CompressedSize(), second.CompressedSize())
+	case SORT_TYPE:
+		if first.FileType() != second.FileType() {
+			return cmp.Compare(FileTypeSortOrder[first.FileType()], FileTypeSortOrder[second.FileType()])
+		}
+		if first.Extension() != second.Extension() {
+			return strings.Compare(first.Extension(), second.Extension())
+		}
+		return strings.Compare(firstName, secondName)
+	case SORT_OWNER:
+		if c := strings.Compare(first.Owner, second.Owner); c != 0 {
+			return c
+		}
+		return strings.Compare(first.Group, second.Group)
+	case SORT_TREE:
+		return cmp.Compare(first.TreeSize(), second.TreeSize())
+	case SORT_RELEVANCE: // Most matches first
+		return cmp.Compare(second.Matches, first.Matches)
+	case SORT_EXT:
+		if first.Extension() == second.Extension() {
+			return strings.Compare(firstName, secondName)
 		}
+		return strings.Compare(first.Extension(), second.Extension())
 	}
-	return ls, err
+	return 0 // SORT_NATURAL; the order read
 }
 
-func filesInTgzArchive(filename string) (ListingSet, error) {
-	var ls ListingSet
-	var gzReader *gzip.Reader
-	var tarReader *tar.Reader
-
-	file, err := os.Open(filename)
-	if err == nil {
-		defer file.Close()
-		gzReader, err = gzip.NewReader(file)
-	}
-	if err == nil {
-		defer gzReader.Close()
-		tarReader = tar.NewReader(gzReader)
-	}
-	if err != nil {
-		if show_errors {
-			fmt.Printf("Error: Could not open %s.  %s\n", filename, err.Error())
+// Prints the rows for a set of files, unless only totals are wanted.
+func printFiles(files []fileitem) {
+	if (listfiles || listdirectories) && !totals_only {
+		if wide_view && !bare {
+			printWide(files)
+			return
 		}
-		return ls, err
-	}
-
-	head, err := tarReader.Next()
-	for head != nil && err == nil {
-		var item fileitem = fileitem{filename, head.Name, head.Size, head.ModTime, time.Time{}, time.Time{}, false, head.FileInfo().Mode(), "", true, NONE}
-		if fileMeetsConditions(item) {
-			ls.MatchedFiles = append(ls.MatchedFiles, item)
-			if item.IsDir {
-				ls.Directorycount++
+		if len(number_rows) > 0 {
+			numberRows(files)
+		}
+		specs, values := columnSpecs(), make([]map[byte]string, len(files))
+		if smart_columns && !bare {
+			specs, values = smartColumns(files)
+		}
+		for i, f := range files {
+			if relative_paths && f.InArchive { // Not something rsync or tar can read
+				continue
+			}
+			if output_template != nil {
+				fmt.Fprintln(output, f.templateOutput())
 			} else {
-				ls.Filecount++
-				ls.Bytesfound += item.Size
+				fmt.Fprintln(output, f.buildOutput(specs, values[i]))
+			}
+			if show_contents && f.IsDir && !f.InArchive {
+				printContents(f)
 			}
 		}
-		head, err = tarReader.Next()
 	}
-	return ls, err
 }
 
-func filesInDirectory(target string) ListingSet {
-	var ls ListingSet
-	var files []fs.DirEntry
-
-	pFile, err := os.Open(target)
-	if err == nil {
-		defer pFile.Close()
-		files, err = pFile.ReadDir(0)
-	}
-	// Iterate through all files, matching and then sort
-	if err == nil {
-		for _, f := range files {
-			fi := makefileitem(f, target)
-			if fileMeetsConditions(fi) {
-				ls.MatchedFiles = append(ls.MatchedFiles, fi)
-				if f.IsDir() {
-					ls.Directorycount++
-				} else {
-					ls.Filecount++
-					i, e := f.Info()
-					if e == nil {
-						ls.Bytesfound += i.Size()
-					}
-				}
-			}
-			// Must be outside of fileMeetsConditions().  Note we cannot use
-			// filetype, because archives may be executable.
-			if fi.IsArchive() && listInArchives {
-				ls.Archives = append(ls.Archives, fi.Name)
-			}
-			if fi.IsDir && listdirectories && (listhidden || fi.Name[0] != '.') {
-				ls.Subdirs = append(ls.Subdirs, fi.Name)
-			}
-
+// For summaries: the oldest and newest files by modification time, e.g. to spot stale directories.
+func printOldestAndNewest(files []fileitem) {
+	var oldest, newest *fileitem
+	for i := range files {
+		if files[i].IsDir {
+			continue
+		}
+		if oldest == nil || files[i].Modified.Before(oldest.Modified) {
+			oldest = &files[i]
+		}
+		if newest == nil || files[i].Modified.After(newest.Modified) {
+			newest = &files[i]
 		}
 	}
-	return ls
+	if oldest != nil {
+		fmt.Fprintf(output, "          Oldest %s  %s\n", formatTime(oldest.Modified), oldest.DisplayName())
+		fmt.Fprintf(output, "          Newest %s  %s\n", formatTime(newest.Modified), newest.DisplayName())
+	}
 }
 
 /******* Core Code *******/
@@ -784,99 +1051,121 @@ func list_directory(target string, recursed bool, isArchive bool) (err error) {
 	var ls ListingSet
 
 	conditionalPrint(debug_messages, "Analyzing directory %s\n", target)
+	if sinceEntries != nil && !isArchive {
+		markDirectoryWalked(target)
+	}
+	if use_dirignore && !isArchive {
+		defer enterIgnoreDirectory(target)()
+	}
+	if use_treeconfig && !isArchive {
+		if !recursed {
+			defer enterParentTreeConfigs(target)()
+		}
+		defer enterTreeConfig(target)()
+	}
 	// Iterate through all files, matching and then sort
+	if isArchive {
+		if !beginArchive(target) {
+			return nil
+		}
+		defer endArchive()
+	}
 	if err == nil {
 		if isArchive {
-			switch FileIsArchiveType(target) {
-			case ARCHIVE_ZIP:
-				ls, err = filesInZipArchive(target, true)
-				conditionalPrint(debug_messages, "Archive %s type zip\n", target)
-			case ARCHIVE_TGZ:
-				ls, err = filesInTgzArchive(target)
-				conditionalPrint(debug_messages, "Archive %s type tgz\n", target)
-			case ARCHIVE_7Z:
-				ls, err = filesIn7ZArchive(target)
-				conditionalPrint(debug_messages, "Archive %s type 7z\n", target)
+			if handler := archiveHandlerFor(target); handler != nil {
+				ls, err = listArchive(handler, target)
+				if text_search_type != SEARCH_NONE {
+					searchStats.archives.Add(1)
+				}
+				conditionalPrint(debug_messages, "Archive %s type %T\n", target, handler)
 			}
 		} else {
 			ls = filesInDirectory(target)
 		}
 	}
-	if err == nil {
-		sort.Slice(ls.MatchedFiles, func(i, j int) bool {
-			first := ls.MatchedFiles[i]
-			second := ls.MatchedFiles[j]
-			firstName := ternaryString(case_sensitive, first.Name, strings.ToUpper(first.Name))
-			secondName := ternaryString(case_sensitive, second.Name, strings.ToUpper(second.Name))
-			if !sortby.ascending {
-				first = ls.MatchedFiles[j]
-				second = ls.MatchedFiles[i]
-			}
-			if (directories_first) && (first.IsDir != second.IsDir) {
-				return first.IsDir
-			}
-			switch sortby.field {
-			case SORT_NAME:
-				return firstName < secondName
-			case SORT_DATE:
-				return first.Modified.Before(second.Modified)
-			case SORT_ACCESSED:
-				return first.Accessed.Before(second.Accessed)
-			case SORT_CREATED:
-				return first.Created.Before(second.Created)
-			case SORT_SIZE:
-				return first.Size < second.Size
-			case SORT_TYPE:
-				if first.FileType() != second.FileType() {
-					return FileTypeSortOrder[first.FileType()] < FileTypeSortOrder[second.FileType()]
-				}
-				if first.Extension() != second.Extension() {
-					return first.Extension() < second.Extension()
-				}
-				return firstName < secondName
-			case SORT_EXT:
-				if first.Extension() == second.Extension() {
-					return firstName < secondName
-				}
-				return first.Extension() < second.Extension()
-			}
-			return first.Name < second.Name
-		})
+	if isArchive && err != nil {
+		// Damaged or truncated: list the archive itself, flagged, after any members that could be read.
+		unreadableArchives++
+		if fi, e := os.Stat(target); e == nil {
+			ls.MatchedFiles = append(ls.MatchedFiles, fileitem{Path: filepath.Dir(target), Name: fi.Name(), Size: fi.Size(),
+				Modified: fi.ModTime(), Mode: fi.Mode(), Unreadable: err.Error()})
+		}
+	}
+	limitResults(&ls)
+	if err == nil && !unordered {
+		sortFiles(ls.MatchedFiles)
 	}
 	TotalBytes += ls.Bytesfound
 	TotalFiles += ls.Filecount
-	// Output results.  Don't print directory header or footer if no files in a recursed directory
-	if (!recursed || len(ls.MatchedFiles) > 0) && directory_header {
-		fmt.Printf("\n   Directory of %s\n", target)
-		if listfiles {
-			fmt.Printf("\n")
-		}
+	queueForActions(ls.MatchedFiles)
+	descents := descentOrder(ls, recursed)
+	var held []byte
+	if prune_empty && !collectingFiles() { // Walked first, to know which directories are empty
+		held = descendHeld(target, descents, &ls)
+		descents = nil
 	}
-	if listfiles || listdirectories {
-		for _, f := range ls.MatchedFiles {
-			fmt.Println(f.BuildOutput())
+	if collectingFiles() { // Printed once everything is found
+		collectedFiles = append(collectedFiles, ls.MatchedFiles...)
+	} else {
+		// Output results.  Don't print directory header or footer if no files in a recursed directory
+		if (!recursed || len(ls.MatchedFiles) > 0) && directory_header {
+			fmt.Fprintf(output, "\n   Directory of %s\n", displayPath(target))
+			if listfiles && !totals_only {
+				fmt.Fprintf(output, "\n")
+			}
+		}
+		printFiles(ls.MatchedFiles)
+		if (!recursed || len(ls.MatchedFiles) > 0) && size_calculations {
+			fmt.Fprintf(output, "   %4d Files (%s bytes) and %4d Directories.\n", ls.Filecount, FileSizeToString(ls.Bytesfound), ls.Directorycount)
+			if totals_only {
+				printOldestAndNewest(ls.MatchedFiles)
+			}
 		}
 	}
-	if (!recursed || len(ls.MatchedFiles) > 0) && size_calculations {
-		fmt.Printf("   %4d Files (%s bytes) and %4d Directories.\n", ls.Filecount, FileSizeToString(ls.Bytesfound), ls.Directorycount)
-	}
 
-	if listInArchives && len(ls.Archives) > 0 {
-		conditionalPrint(debug_messages, "Listing in Archives %s\n", ls.Archives)
-		sort.Strings(ls.Archives)
-		for _, d := range ls.Archives {
-			list_directory(filepath.Join(target, d), true, true)
+	output.Write(held)
+
+	// Handle archives and sub directories
+	for _, next := range descents {
+		if outOfTime() {
+			break
 		}
+		descend(target, next)
 	}
-	// Handle sub directories
-	if recurse_directories {
-		sort.Strings(ls.Subdirs)
-		for _, d := range ls.Subdirs {
-			list_directory(filepath.Join(target, d), true, false)
-		}
+	if !recursed && output_json && jsonSummaryWanted() {
+		printJSONSummary(collectedFiles)
+	} else if !recursed && output_json {
+		printJSON(collectedFiles)
+	} else if !recursed && rollup {
+		printRollup(collectedFiles)
+	} else if !recursed && recent {
+		printRecent(collectedFiles)
+	} else if !recursed && hardlink_report {
+		printHardLinks(collectedFiles)
+	} else if !recursed && audit_secrets {
+		printAudit(collectedFiles)
+	} else if !recursed && len(histogram_by) > 0 {
+		printHistogram(collectedFiles)
+	} else if !recursed && len(group_by) > 0 {
+		printGroups(collectedFiles)
+	}
+	if (recurse_directories || collectingFiles()) && !recursed && size_calculations {
+		fmt.Fprintf(output, "\n   %4d Total Files (%s Total Bytes) listed.\n", TotalFiles, FileSizeToString(TotalBytes))
+	}
+	if !recursed && size_calculations && text_search_type != SEARCH_NONE {
+		printSearchStats()
+	}
+	if !recursed && size_calculations && unreadableArchives > 0 {
+		fmt.Fprintf(output, "   %4d Archives could not be read.\n", unreadableArchives)
 	}
-	if recurse_directories && !recursed {
-		fmt.Printf("\n   %4d Total Files (%s Total Bytes) listed.\n", TotalFiles, FileSizeToString(TotalBytes))
+	if !recursed && size_calculations && clones {
+		printCloneTotals()
+	}
+	if !recursed && size_calculations {
+		printWalkStats()
+	}
+	if !recursed {
+		printTruncationNotice()
 	}
 	return err
 }
@@ -886,12 +1175,52 @@ func main() {
 	parseCmdLine()
 	if debug_messages {
 		for c := NONE; c <= DEFAULT; c++ {
-			fmt.Printf("Color for %16s is %s\n", c.String(), FileColors[c])
+			fmt.Fprintf(output, "Color for %16s is %s\n", c.String(), FileColors[c])
 		}
 	}
 
 	if len(start_directory) == 0 || start_directory == "." {
 		start_directory, _ = os.Getwd()
 	}
+	if explain {
+		printExplain()
+		return
+	}
+	if len(since_file) > 0 {
+		loadSinceSnapshot()
+	}
+	startPager()
+	startOutput()
+	searchStats.started = time.Now()
+	startRunBudget()
+	defer cancelRun()
+	if list_mounts {
+		printMounts()
+		flushOutput()
+		stopPager()
+		return
+	}
+	if watch_mode {
+		watchDirectory(start_directory)
+		return
+	}
+	if len(pick_rows) > 0 {
+		pickRows()
+		flushOutput()
+		stopPager()
+		runActions()
+		flushOutput()
+		return
+	}
 	list_directory(start_directory, false, pathIsArchive)
+	finishSnapshots()
+	saveListingCache()
+	saveNumberedRows()
+	flushOutput()
+	stopPager()
+	runActions()
+	flushOutput()
+	if first_match && !limitReached.Load() {
+		os.Exit(1) // Nothing found, for scripts
+	}
 }
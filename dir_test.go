@@ -0,0 +1,114 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The file is searched search-window bytes at a time, carrying the tail of each window into the
+// next.  Matches before, at and across the boundaries must be found, and counted once.
+func TestDiskFileTextSearchWindows(t *testing.T) {
+	const window = 2 * searchOverlap // Boundaries at 8192 and 16384; 4096 bytes carried
+	defer func(w int) { search_window = w }(search_window)
+	search_window = window
+
+	tests := []struct {
+		name    string
+		pattern string
+		text    string
+		at      []int
+		found   int // Without count_matches
+		counted int // With
+	}{
+		{"inside the first window", "needle", "needle", []int{100}, 1, 1},
+		{"across a boundary", "needle", "needle", []int{window - 2}, 1, 1},
+		{"ending at a boundary", "needle", "needle", []int{window - 6}, 1, 1},
+		{"starting at a boundary", "needle", "needle", []int{window}, 1, 1},
+		{"either side of a boundary", "needle", "needle", []int{window - 6, window}, 1, 2},
+		{"in the carried tail and across", "needle", "needle", []int{window - 3000, window - 2}, 1, 2},
+		{"in every window", "needle", "needle", []int{100, window - 2, 2*window - 3, 2*window + 50}, 1, 4},
+		{"growing across a boundary", "ab+", "a" + string(bytes.Repeat([]byte("b"), 300)), []int{window - 100}, 1, 1},
+		{"long, across a boundary", "BEGIN-+END", "BEGIN" + string(bytes.Repeat([]byte("-"), 100)) + "END", []int{window - 50}, 1, 1},
+		// Matches must start within searchOverlap of the boundary to be found across it.
+		{"longer than the overlap, across", "BEGIN-+END", "BEGIN" + string(bytes.Repeat([]byte("-"), 5000)) + "END", []int{window - 4500}, 0, 0},
+		{"none", "needle", "", nil, 0, 0},
+	}
+	dir := t.TempDir()
+	for _, test := range tests {
+		data := bytes.Repeat([]byte("x"), 3*window)
+		for _, at := range test.at {
+			copy(data[at:], test.text)
+		}
+		name := "data.txt"
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		text_regex = regexp.MustCompile(test.pattern)
+		for _, counting := range []bool{false, true} {
+			count_matches = counting
+			f := fileitem{Path: dir, Name: name, Size: int64(len(data))}
+			want := ternaryInt(counting, test.counted, test.found)
+			if got := diskFileTextSearch(&f); got != want {
+				t.Errorf("%s (counting %v): %d matches, want %d", test.name, counting, got, want)
+			}
+		}
+	}
+	count_matches, text_regex = false, nil
+}
+
+// Several workers search at once, printing errors for the files they can't open.  Run with -race.
+func TestSearchFilesWorkers(t *testing.T) {
+	defer func(w int, o io.Writer) { searchWorkers = w; setOutput(o) }(searchWorkers, output)
+	searchWorkers = 4
+	var printed bytes.Buffer
+	setOutput(&printed)
+	show_errors, text_search_type, text_regex = true, SEARCH_REGEX, regexp.MustCompile("needle")
+	defer func() { show_errors, text_search_type, text_regex = false, SEARCH_NONE, nil }()
+
+	dir := t.TempDir()
+	var files []fileitem
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("%03d.txt", i)
+		if i%2 == 0 { // The odd ones are missing
+			text := ternaryString(i%4 == 0, "a needle", "hay")
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		files = append(files, fileitem{Path: dir, Name: name, Size: 8})
+	}
+	matched := searchFiles(files)
+	if len(matched) != 25 {
+		t.Errorf("%d files matched, want 25", len(matched))
+	}
+	for i, f := range matched {
+		if want := fmt.Sprintf("%03d.txt", 4*i); f.Name != want {
+			t.Errorf("match %d is %s, want %s", i, f.Name, want)
+		}
+	}
+	if errors := strings.Count(printed.String(), "Could not open file for text search"); errors != 50 {
+		t.Errorf("%d errors printed, want 50", errors)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// .dirignore files: gitignore-style patterns, read from each directory as it is walked, that hide
+// files and directories from that directory down.  Ignored directories are not walked at all.
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+const dirignoreFile = ".dirignore"
+
+// One pattern line.  Patterns with a slash other than at the end are matched against the path
+// from the .dirignore's directory; others against the name alone, at any depth.
+type ignoreRule struct {
+	base     string // The directory holding the .dirignore
+	matcher  glob.Glob
+	anchored bool
+	negate   bool // ! - brings back something an earlier pattern ignored
+	dirsOnly bool // Trailing / - only matches directories
+}
+
+var (
+	use_dirignore bool         = true
+	ignoreRules   []ignoreRule // From the directories being walked, outermost first
+)
+
+// Reads target's .dirignore, if any, adding its patterns to those in force.  Returns a function
+// to call when leaving the directory, which drops them again.
+func enterIgnoreDirectory(target string) func() {
+	before := len(ignoreRules)
+	file, err := os.Open(filepath.Join(target, dirignoreFile))
+	if err != nil {
+		return func() {}
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(target, scanner.Text()); ok {
+			ignoreRules = append(ignoreRules, rule)
+		}
+	}
+	conditionalPrint(debug_messages, "%d patterns from %s\n", len(ignoreRules)-before, filepath.Join(target, dirignoreFile))
+	return func() { ignoreRules = ignoreRules[:before] }
+}
+
+// Parses one line of a .dirignore, as git does a .gitignore.
+func parseIgnoreRule(base string, line string) (ignoreRule, bool) {
+	rule := ignoreRule{base: base}
+	line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " ")
+	if len(line) == 0 || line[0] == '#' {
+		return rule, false
+	}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+		line = line[1:]
+	}
+	rule.dirsOnly = strings.HasSuffix(line, "/")
+	line = strings.TrimSuffix(line, "/")
+	line = strings.TrimPrefix(line, "**/")
+	rule.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if len(line) == 0 {
+		return rule, false
+	}
+	// Braces are literal in gitignore, but alternatives to glob.  "a/**/b" also matches "a/b".
+	pattern := strings.NewReplacer("{", "\\{", "}", "\\}", ",", "\\,").Replace(line)
+	if strings.Contains(pattern, "/**/") {
+		pattern = "{" + pattern + "," + strings.ReplaceAll(pattern, "/**/", "/") + "}"
+	}
+	matcher, err := glob.Compile(pattern, '/')
+	if err != nil {
+		conditionalPrint(show_errors, "Bad pattern in %s: %s\n", filepath.Join(base, dirignoreFile), line)
+		return rule, false
+	}
+	rule.matcher = matcher
+	return rule, true
+}
+
+// True if a .dirignore in force hides the file.  The last pattern that matches decides.
+func ignoredByDirignore(path string, name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range ignoreRules {
+		if rule.dirsOnly && !isDir {
+			continue
+		}
+		subject := name
+		if rule.anchored {
+			rel, err := filepath.Rel(rule.base, filepath.Join(path, name))
+			if err != nil {
+				continue
+			}
+			subject = filepath.ToSlash(rel)
+		}
+		if rule.matcher.Match(subject) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
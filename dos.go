@@ -0,0 +1,117 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Classic cmd.exe dir switches - /s, /b, /w, /p, /a:h, /o:d, /t:c - translated to our flags.
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// /x, /a{:}{-}attrs, /o{:}{-}order and /t{:}field.  An existing path like /opt is never a switch.
+var dosSwitch = regexp.MustCompile(`^/(?i:([bpsw])|(a):?([-a-z]*)|(o):?([-a-z]+)|(t):?([acw]))$`)
+
+// Translates DOS switches to flags.  Switches dir already accepts, like /on, pass through.
+func expandDOSSwitches(args []string) []string {
+	dateSort := SORT_DATE // /t picks the time /o:d sorts by
+	for _, a := range args {
+		if m := dosSwitch.FindStringSubmatch(a); m != nil && len(m[6]) > 0 && !pathExists(a) {
+			dateSort = map[string]sortfield{"a": SORT_ACCESSED, "c": SORT_CREATED, "w": SORT_DATE}[strings.ToLower(m[7])]
+		}
+	}
+	var expanded []string
+	for _, a := range args {
+		m := dosSwitch.FindStringSubmatch(a)
+		if m == nil || pathExists(a) {
+			expanded = append(expanded, a)
+			continue
+		}
+		var flags []string
+		switch {
+		case len(m[1]) > 0:
+			flags = []string{map[string]string{"b": "-b", "p": "-pause", "s": "-r", "w": "-wide"}[strings.ToLower(m[1])]}
+		case len(m[2]) > 0:
+			flags = dosAttributes(strings.ToLower(m[3]))
+		case len(m[4]) > 0:
+			flags = dosOrder(strings.ToLower(m[5]), dateSort)
+		case len(m[6]) > 0: // Shows that time instead of modified
+			column := map[string]string{"a": COLUMN_DATEACCESSED, "c": COLUMN_DATECREATED, "w": COLUMN_DATEMODIFIED}[strings.ToLower(m[7])]
+			flags = []string{"-c=p   " + column + "  s   nl"}
+		}
+		conditionalPrint(debug_messages, "DOS switch %s is %v\n", a, flags)
+		expanded = append(expanded, flags...)
+	}
+	return expanded
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// /a:dh, /a:-d and so on.  d = directories, h = hidden, r = read-only; - negates.  /a alone is everything.
+func dosAttributes(attrs string) []string {
+	var flags []string
+	negate := false
+	for _, c := range attrs {
+		switch c {
+		case '-':
+			negate = true
+			continue
+		case 'd':
+			flags = append(flags, ternaryString(negate, "-d-", "-d+"))
+		case 'h':
+			flags = append(flags, ternaryString(negate, "-ah-", "-ah+"))
+		case 'r':
+			flags = append(flags, ternaryString(negate, "-ar-", "-ar+"))
+		default:
+			conditionalPrint(show_errors, "Attribute %c is not supported; use d, h or r.\n", c)
+		}
+		negate = false
+	}
+	return flags
+}
+
+// /o:-d, /o:gn and so on.  n = name, e = extension, s = size, d = date, g = directories first.
+// Letters we use differently, like t and x, keep our meaning.
+func dosOrder(order string, dateSort sortfield) []string {
+	var flags []string
+	negate := false
+	for _, c := range order {
+		field := string(c)
+		switch c {
+		case '-':
+			negate = true
+			continue
+		case 'g': // Directories are grouped first by default; -g puts them among the files
+			if negate {
+				flags = append(flags, "-dirs-mixed")
+			}
+			negate = false
+			continue
+		case 'e':
+			field = string(SORT_EXT)
+		case 'd':
+			field = string(dateSort)
+		}
+		flags = append(flags, "-o"+ternaryString(negate, "-", "")+field)
+		negate = false
+	}
+	return flags
+}
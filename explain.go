@@ -0,0 +1,115 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -explain: what the command line, config file and aliases came to, without listing anything, to
+// see why a command doesn't find what was expected.
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+var explain bool
+
+func printExplain() {
+	line := func(label string, format string, a ...any) {
+		fmt.Fprintf(output, "%-18s %s\n", label+":", fmt.Sprintf(format, a...))
+	}
+	line("Config file", "%s%s", ternaryString(len(configPath) > 0, configPath, "(none)"), ternaryString(portable, ", portable", ""))
+	line("Start directory", "%s%s", start_directory, ternaryString(pathIsArchive, " (an archive)", ""))
+	if haveGlobber {
+		masks := strings.Join(file_masks, ",")
+		line("File mask", "%s, matched as %q, %s", masks, ternaryString(case_sensitive, masks, strings.ToUpper(masks)),
+			ternaryString(case_sensitive, "case sensitive", "ignoring case"))
+	} else {
+		line("File mask", "(none: everything)")
+	}
+	recursion := "no"
+	if recurse_directories {
+		recursion = ternaryString(max_depth < 0, "yes, all the way down", fmt.Sprintf("yes, %d levels down", max_depth))
+	}
+	line("Recurse", "%s", recursion)
+	archives := "not opened"
+	if archives_only {
+		archives = "listed themselves, not their members"
+	} else if listInArchives {
+		archives = "opened" + ternaryString(archives_top_only, ", in the start directory only", "") + ", listed " +
+			map[string]string{"first": "before subdirectories", "last": "after subdirectories", "mixed": "among subdirectories by name"}[archive_order]
+	}
+	line("Archives", "%s", archives)
+	filters := activeFilters()
+	line("Filters", "%s", ternaryString(len(filters) > 0, strings.Join(filters, "; "), "(none)"))
+	search := "(none)"
+	switch {
+	case text_search_type == SEARCH_HEX:
+		search = "hex bytes"
+	case len(patterns_file) > 0:
+		search = fmt.Sprintf("patterns from %s, as %s", patterns_file, text_regex)
+	case text_search_type != SEARCH_NONE && text_regex != nil:
+		search = fmt.Sprintf("%q, as the regular expression %s", search_text, text_regex)
+	}
+	line("Content search", "%s", search)
+	line("Sort", "by %s, %s, ties by %s%s%s", sortby.field, ternaryString(sortby.ascending, "ascending", "descending"), sort_tiebreak,
+		ternaryString(directories_first, ", directories first", ""), ternaryString(unordered, " (unordered: not sorted)", ""))
+	columns := fmt.Sprintf("%q", columnDef)
+	switch {
+	case bare:
+		columns = "names only (bare)"
+	case output_json:
+		columns = "JSON"
+	case output_template != nil:
+		columns = "the -format template"
+	}
+	line("Columns", "%s", columns)
+	line("Colors", "%s", ternaryString(!use_colors, "off", ternaryString(use_enhanced_colors, "on, enhanced", "on")))
+	line("Shown", "%s%s%s", ternaryString(listfiles, "files", ""), ternaryString(listfiles && listdirectories, " and ", ""),
+		ternaryString(listdirectories, "directories", "")+ternaryString(listhidden, ", hidden included", ", not hidden"))
+}
+
+// The filters that will leave files out, in words.
+func activeFilters() []string {
+	var filters []string
+	add := func(condition bool, format string, a ...any) {
+		if condition {
+			filters = append(filters, fmt.Sprintf(format, a...))
+		}
+	}
+	add(len(exclude_exts) > 0, "not extensions %s", strings.Join(exclude_exts, ","))
+	add(len(exclude_dirs) > 0, "not directories %s", strings.Join(exclude_dirs, ","))
+	add(only_hidden, "hidden only")
+	add(!mindate.IsZero(), "%s from %s", minmaxdatetype, mindate.Format("2006-01-02 15:04:05"))
+	add(!maxdate.IsZero(), "%s to %s", minmaxdatetype, maxdate.Format("2006-01-02 15:04:05"))
+	add(minsize >= 0, "at least %d bytes", minsize)
+	add(maxsize < math.MaxInt64, "at most %d bytes", maxsize)
+	add(min_compressed >= 0 || max_compressed < math.MaxInt64, "compressed %d to %d bytes", min_compressed, max_compressed)
+	add(min_entries >= 0 || max_entries < math.MaxInt64, "directories with %d to %d entries", min_entries, max_entries)
+	add(linkCountFilter(), "%d to %d hard links", min_links, max_links)
+	add(readonly_filter > 0, "read-only")
+	add(readonly_filter < 0, "writable")
+	add(access_required|access_refused != 0, "access required %03b, refused %03b (rwx)", access_required, access_refused)
+	add(len(perm_filters) > 0, "%d -perm filters", len(perm_filters))
+	add(linkto_matcher != nil, "symlinks to matching paths")
+	add(len(languages) > 0, "languages %s", strings.Join(languages, ","))
+	add(sensitive_only, "likely secrets")
+	add(skip_generated, "not generated files")
+	add(len(member_pattern) > 0, "archives holding %s", member_pattern)
+	add(len(search_types) > 0 || len(search_exts) > 0, "content searched in some types only (-tt)")
+	add(max_results > 0, "stop after %d", max_results)
+	return filters
+}
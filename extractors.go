@@ -0,0 +1,122 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Content extractors: how the text of a document is got at for a content search, by extension.
+// Files without one are plain text as far as the search is concerned, and are searched as they
+// are.  Support for another format is a ContentExtractor and a registerContentExtractor call.
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ContentExtractor interface {
+	// The searchable text of a file on disk.
+	FileText(filename string) ([]byte, error)
+	// The searchable text of a file read into memory, such as an archive member.
+	MemberText(name string, data []byte) ([]byte, error)
+}
+
+var contentExtractors = map[string]ContentExtractor{} // By upper-case extension
+
+func registerContentExtractor(extractor ContentExtractor, extensions ...string) {
+	for _, ext := range extensions {
+		contentExtractors[strings.ToUpper(ext)] = extractor
+	}
+}
+
+// The extractor for an extension, or nil to search the bytes as they are.
+func extractorFor(ext string) ContentExtractor {
+	return contentExtractors[strings.ToUpper(ext)]
+}
+
+func init() {
+	registerContentExtractor(officeExtractor{}, "docx", "pptx", "xlsx", "vsdx")
+	registerContentExtractor(pdfExtractor{}, "pdf")
+}
+
+// Office Open XML files are zips of XML parts; the text is in the parts.
+type officeExtractor struct{}
+
+func (officeExtractor) FileText(filename string) ([]byte, error) {
+	zipReader, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer zipReader.Close()
+	compressed := int64(0)
+	if fi, err := os.Stat(filename); err == nil {
+		compressed = fi.Size()
+	}
+	return zipPartsText(&zipReader.Reader, filepath.Base(filename), compressed), nil
+}
+
+func (officeExtractor) MemberText(name string, data []byte) ([]byte, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return zipPartsText(zipReader, name, int64(len(data))), nil
+}
+
+// The parts of a zip, a line apart, skipping any too large to read into memory.
+func zipPartsText(zipReader *zip.Reader, name string, compressed int64) []byte {
+	guard := archiveGuard{name: name, compressed: compressed}
+	var text bytes.Buffer
+	for _, fileInZip := range zipReader.File {
+		if !guard.allow(int64(fileInZip.UncompressedSize64)) {
+			break
+		}
+		if fileInZip.UncompressedSize64 > maxArchiveMemberBytes {
+			continue
+		}
+		readCloser, err := fileInZip.Open()
+		if err != nil {
+			continue
+		}
+		io.Copy(&text, io.LimitReader(readCloser, maxArchiveMemberBytes))
+		readCloser.Close()
+		text.WriteByte('\n')
+	}
+	return text.Bytes()
+}
+
+// PDFs go through the PDF helper, which needs a file.
+type pdfExtractor struct{}
+
+func (pdfExtractor) FileText(filename string) ([]byte, error) {
+	s, err := PDFText(filename, true)
+	return []byte(s), err
+}
+
+func (pdfExtractor) MemberText(name string, data []byte) ([]byte, error) {
+	pfile, err := os.CreateTemp("", "*-"+filepath.Base(name))
+	if err != nil {
+		return nil, err
+	}
+	pfilename := pfile.Name()
+	defer os.Remove(pfilename)
+	pfile.Write(data)
+	pfile.Close()
+	s, err := PDFText(pfilename, true)
+	return []byte(s), err
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -fast: names only, from the directory entries alone, without asking the file system for each
+// one's size, times and permissions.  That is most of the work on a huge tree, and on network
+// file systems in particular.
+
+import (
+	"io/fs"
+	"math"
+)
+
+var (
+	fast_mode bool // -fast was given
+	stat_free bool // -fast, and nothing asked for needs the details
+)
+
+// Why the entries' details are needed despite -fast, or "" if they aren't.
+func detailsNeededFor() string {
+	switch {
+	case !bare:
+		return "columns"
+	case !mindate.IsZero() || !maxdate.IsZero():
+		return "date filters"
+	case minsize >= 0 || maxsize < math.MaxInt64 || min_compressed >= 0 || max_compressed < math.MaxInt64:
+		return "size filters"
+	case readonly_filter != 0 || access_required|access_refused != 0 || len(perm_filters) > 0:
+		return "permission filters"
+	case linkto_matcher != nil || linkCountFilter():
+		return "link filters"
+	case !nameOnlySort(sortby.field) || !nameOnlySort(sort_tiebreak):
+		return "the sort order"
+	case len(search_types) > 0 || group_by == GROUP_TYPE || group_by == GROUP_DATE:
+		return "file types and dates"
+	case len(histogram_by) > 0 || group_by == GROUP_OWNER || hardlink_report || audit_secrets || count_links_once || output_json || rollup || show_contents:
+		return "the report"
+	case len(snapshot_file) > 0 || len(since_file) > 0: // The snapshot isn't loaded until after the flags
+		return "snapshots"
+	}
+	return ""
+}
+
+// True for sorts on what a directory entry has: its name and where it is.
+func nameOnlySort(field sortfield) bool {
+	return field == SORT_NAME || field == SORT_EXT || field == SORT_PATH || field == SORT_NATURAL || field == SORT_RELEVANCE
+}
+
+// Decides whether -fast can skip the details, once all the flags are known.
+func applyFast() {
+	if why := detailsNeededFor(); len(why) > 0 {
+		conditionalPrint(show_errors || debug_messages, "-fast: reading file details anyway, for %s.\n", why)
+		return
+	}
+	stat_free = true
+}
+
+// A fileitem from the entry alone: name, and whether it's a directory or link.
+func entryFileitem(de fs.DirEntry, path string) fileitem {
+	return fileitem{Path: path, Name: de.Name(), IsDir: de.IsDir(), Mode: de.Type()}
+}
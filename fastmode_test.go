@@ -0,0 +1,75 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// Any filter that reads what -fast leaves out must turn the details back on: with stat_free, a
+// directory entry has to pass or fail the filters just as the full fileitem does.
+func TestFastFiltersNeedDetails(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().AddDate(-1, 0, 0)
+	os.WriteFile(filepath.Join(dir, "f.txt"), []byte("0123456789"), 0644)
+	os.Chtimes(filepath.Join(dir, "f.txt"), old, old)
+	os.WriteFile(filepath.Join(dir, "ro.txt"), nil, 0444)
+	os.Symlink("f.txt", filepath.Join(dir, "link"))
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := []struct {
+		name  string
+		setup func()
+	}{
+		{"none", func() {}},
+		{"-ms", func() { minsize = 5 }},
+		{"-md", func() { mindate = time.Now().AddDate(0, -1, 0) }},
+		{"-ar+", func() { readonly_filter = 1 }},
+		{"-perm", func() { f, _ := parsePermFilter("644"); perm_filters = []permFilter{f} }},
+		{"-linkto", func() { linkto_matcher = glob.MustCompile("*f.txt") }},
+		{"-links-count", func() { min_links = 1 }},
+		{"-mz", func() { min_compressed = 1 }},
+		{"-ah+", func() { only_hidden = true }},
+	}
+	for _, filter := range filters {
+		bare, stat_free = true, false
+		minsize, mindate, readonly_filter, perm_filters, linkto_matcher = -1, time.Time{}, 0, nil, nil
+		min_links, min_compressed, only_hidden = -1, -1, false
+		filter.setup()
+		if len(detailsNeededFor()) > 0 {
+			continue
+		}
+		for _, e := range entries {
+			full, fast := makefileitem(e, dir), entryFileitem(e, dir)
+			if fileMeetsFilters(&full) != fileMeetsFilters(&fast) {
+				t.Errorf("%s: %s is listed differently with -fast", filter.name, e.Name())
+			}
+		}
+	}
+	bare, minsize, min_links, min_compressed = false, -1, -1, -1
+	maxsize, max_links = math.MaxInt64, math.MaxInt64
+}
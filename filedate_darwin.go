@@ -21,6 +21,14 @@ import (
 	"time"
 )
 
+// What the c column shows, for -version=json.
+const createdTimeSource = "birth"
+
 func createdAndAccessed(fi fs.FileInfo) (time.Time, time.Time) {
 	return time.Unix(fi.Sys().(*syscall.Stat_t).Birthtimespec.Unix()), time.Unix(fi.Sys().(*syscall.Stat_t).Atimespec.Unix())
 }
+
+// The inode change time, when the contents or metadata (permissions, owner, links) last changed.
+func changedTime(fi fs.FileInfo) time.Time {
+	return time.Unix(fi.Sys().(*syscall.Stat_t).Ctimespec.Unix())
+}
@@ -22,7 +22,15 @@ import (
 	"time"
 )
 
+// What the c column shows, for -version=json: the inode change time.
+const createdTimeSource = "change"
+
 // Linux has Atim, Mtim, Ctim. They don't seem to be accurate though; Ctim seems to, on my FS, match Mtim.
 func createdAndAccessed(fi fs.FileInfo) (time.Time, time.Time) {
 	return time.Unix(fi.Sys().(*syscall.Stat_t).Ctimespec.Unix()), time.Unix(fi.Sys().(*syscall.Stat_t).Atimespec.Unix())
 }
+
+// The inode change time, when the contents or metadata (permissions, owner, links) last changed.
+func changedTime(fi fs.FileInfo) time.Time {
+	return time.Unix(fi.Sys().(*syscall.Stat_t).Ctimespec.Unix())
+}
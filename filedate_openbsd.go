@@ -22,7 +22,15 @@ import (
 	"time"
 )
 
+// What the c column shows, for -version=json: the inode change time.
+const createdTimeSource = "change"
+
 // Oddly, OpenBSD matches Linux struct names, not FreeBSD struct names!
 func createdAndAccessed(fi fs.FileInfo) (time.Time, time.Time) {
 	return time.Unix(fi.Sys().(*syscall.Stat_t).Ctim.Unix()), time.Unix(fi.Sys().(*syscall.Stat_t).Atim.Unix())
 }
+
+// The inode change time, when the contents or metadata (permissions, owner, links) last changed.
+func changedTime(fi fs.FileInfo) time.Time {
+	return time.Unix(fi.Sys().(*syscall.Stat_t).Ctim.Unix())
+}
@@ -22,8 +22,16 @@ import (
 	"time"
 )
 
+// What the c column shows, for -version=json.
+const createdTimeSource = "birth"
+
 func createdAndAccessed(fi fs.FileInfo) (time.Time, time.Time) {
 	var createdTime syscall.Filetime = fi.Sys().(*syscall.Win32FileAttributeData).CreationTime
 	var accessTime syscall.Filetime = fi.Sys().(*syscall.Win32FileAttributeData).LastAccessTime
 	return time.Unix(0, createdTime.Nanoseconds()), time.Unix(0, accessTime.Nanoseconds())
 }
+
+// Windows keeps a change time, but not in the attribute data, so it isn't shown.
+func changedTime(fi fs.FileInfo) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,87 @@
+//go:build !windows
+
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Unix-specific file details: owner and group names, from the uid and gid, and the link count.
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Errors worth retrying, as a network file system may recover.
+var transientErrors = []error{syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT, syscall.ECONNRESET, syscall.EHOSTDOWN,
+	syscall.ESTALE, syscall.EIO}
+
+// Ids already looked up.  Listings tend to have only a few owners.
+var (
+	userNames  = map[uint32]string{}
+	groupNames = map[uint32]string{}
+)
+
+func ownerAndGroup(filename string, fi fs.FileInfo) (string, string) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+	uid, gid := uint32(stat.Uid), uint32(stat.Gid)
+	if _, found := userNames[uid]; !found {
+		userNames[uid] = strconv.FormatUint(uint64(uid), 10)
+		if u, err := user.LookupId(userNames[uid]); err == nil {
+			userNames[uid] = u.Username
+		}
+	}
+	if _, found := groupNames[gid]; !found {
+		groupNames[gid] = strconv.FormatUint(uint64(gid), 10)
+		if g, err := user.LookupGroupId(groupNames[gid]); err == nil {
+			groupNames[gid] = g.Name
+		}
+	}
+	return userNames[uid], groupNames[gid]
+}
+
+// Number of hard links to the file.
+func linkCount(fi fs.FileInfo) uint64 {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
+
+// Device and inode, which identify the data hard links share, and the number of links.
+func fileID(filename string, fi fs.FileInfo) (fileid, uint64) {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return fileid{uint64(stat.Dev), uint64(stat.Ino)}, uint64(stat.Nlink)
+	}
+	return fileid{}, 1
+}
+
+// What the current (effective) user can actually do with the file: ACCESS_READ, ACCESS_WRITE and
+// ACCESS_EXECUTE, as the kernel decides, so ACLs, read-only mounts and root are all accounted for.
+func effectiveAccess(filename string, mode fs.FileMode) int {
+	access := 0
+	for _, want := range []int{ACCESS_READ, ACCESS_WRITE, ACCESS_EXECUTE} {
+		if accessCheck(filename, uint32(want)) == nil {
+			access |= want
+		}
+	}
+	return access
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Windows-specific file details.  The owner comes from the file's security descriptor.  Windows
+// has a primary group, but it is rarely meaningful, so it is left blank.
+
+import (
+	"io/fs"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                 = syscall.NewLazyDLL("advapi32.dll")
+	procGetNamedSecurityInfo = advapi32.NewProc("GetNamedSecurityInfoW")
+	procGetEffectiveRights   = advapi32.NewProc("GetEffectiveRightsFromAclW")
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	procLocalFree            = kernel32.NewProc("LocalFree")
+)
+
+// Errors worth retrying, as a network share may recover.
+var transientErrors = []error{syscall.ERROR_NETNAME_DELETED, syscall.Errno(59), // ERROR_UNEXP_NET_ERR
+	syscall.Errno(121)} // ERROR_SEM_TIMEOUT
+
+const (
+	SE_FILE_OBJECT             = 1
+	OWNER_SECURITY_INFORMATION = 1
+	DACL_SECURITY_INFORMATION  = 4
+	TRUSTEE_IS_SID             = 0
+	TRUSTEE_IS_USER            = 1
+	FILE_READ_DATA             = 0x1
+	FILE_WRITE_DATA            = 0x2
+	FILE_EXECUTE               = 0x20
+	GENERIC_ALL                = 0x10000000
+)
+
+// TRUSTEE_W, naming the user whose rights are wanted.
+type trustee struct {
+	multipleTrustee          uintptr
+	multipleTrusteeOperation int32
+	trusteeForm              int32
+	trusteeType              int32
+	name                     uintptr
+}
+
+var currentUserSID *syscall.SID // Looked up once, for effectiveAccess
+
+func ownerAndGroup(filename string, fi fs.FileInfo) (string, string) {
+	name, err := syscall.UTF16PtrFromString(filename)
+	if err != nil {
+		return "", ""
+	}
+	var owner *syscall.SID
+	var descriptor uintptr
+	ret, _, _ := procGetNamedSecurityInfo.Call(uintptr(unsafe.Pointer(name)), SE_FILE_OBJECT, OWNER_SECURITY_INFORMATION,
+		uintptr(unsafe.Pointer(&owner)), 0, 0, 0, uintptr(unsafe.Pointer(&descriptor)))
+	if ret != 0 || owner == nil {
+		conditionalPrint(debug_messages, "GetNamedSecurityInfo failed on %s: %d\n", filename, ret)
+		return "", ""
+	}
+	defer procLocalFree.Call(descriptor)
+	account, domain, _, err := owner.LookupAccount("")
+	if err != nil {
+		if s, e := owner.String(); e == nil {
+			return s, ""
+		}
+		return "", ""
+	}
+	return domain + "\\" + account, ""
+}
+
+// Number of hard links.  FindFirstFile doesn't provide it, and opening every file to ask is
+// slow, so this is 1 unless fileID is asked.
+func linkCount(fi fs.FileInfo) uint64 {
+	return 1
+}
+
+// Volume serial number and file index, which identify the data hard links share, and the number
+// of links.  This opens the file, so is only done when needed.
+func fileID(filename string, fi fs.FileInfo) (fileid, uint64) {
+	name, err := syscall.UTF16PtrFromString(filename)
+	if err != nil {
+		return fileid{}, 1
+	}
+	handle, err := syscall.CreateFile(name, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		conditionalPrint(debug_messages, "Could not open %s for its file id: %s\n", filename, err.Error())
+		return fileid{}, 1
+	}
+	defer syscall.CloseHandle(handle)
+	var info syscall.ByHandleFileInformation
+	if err = syscall.GetFileInformationByHandle(handle, &info); err != nil {
+		return fileid{}, 1
+	}
+	return fileid{uint64(info.VolumeSerialNumber), uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)}, uint64(info.NumberOfLinks)
+}
+
+// What the current user may do with the file, according to its ACL: ACCESS_READ, ACCESS_WRITE
+// and ACCESS_EXECUTE.  The read-only attribute also prevents writing to files.
+func effectiveAccess(filename string, mode fs.FileMode) int {
+	if currentUserSID == nil {
+		token, err := syscall.OpenCurrentProcessToken()
+		if err != nil {
+			return 0
+		}
+		defer token.Close()
+		user, err := token.GetTokenUser()
+		if err != nil {
+			return 0
+		}
+		currentUserSID = user.User.Sid
+	}
+	name, err := syscall.UTF16PtrFromString(filename)
+	if err != nil {
+		return 0
+	}
+	var dacl, descriptor uintptr
+	ret, _, _ := procGetNamedSecurityInfo.Call(uintptr(unsafe.Pointer(name)), SE_FILE_OBJECT, DACL_SECURITY_INFORMATION,
+		0, 0, uintptr(unsafe.Pointer(&dacl)), 0, uintptr(unsafe.Pointer(&descriptor)))
+	if ret != 0 {
+		conditionalPrint(debug_messages, "GetNamedSecurityInfo failed on %s: %d\n", filename, ret)
+		return 0
+	}
+	defer procLocalFree.Call(descriptor)
+	if dacl == 0 { // No DACL means everyone has full access.
+		return ACCESS_READ | ACCESS_WRITE | ACCESS_EXECUTE
+	}
+	who := trustee{trusteeForm: TRUSTEE_IS_SID, trusteeType: TRUSTEE_IS_USER, name: uintptr(unsafe.Pointer(currentUserSID))}
+	var rights uint32
+	ret, _, _ = procGetEffectiveRights.Call(dacl, uintptr(unsafe.Pointer(&who)), uintptr(unsafe.Pointer(&rights)))
+	if ret != 0 {
+		conditionalPrint(debug_messages, "GetEffectiveRightsFromAcl failed on %s: %d\n", filename, ret)
+		return 0
+	}
+	if rights&GENERIC_ALL != 0 {
+		rights |= FILE_READ_DATA | FILE_WRITE_DATA | FILE_EXECUTE
+	}
+	access := 0
+	if rights&FILE_READ_DATA != 0 {
+		access |= ACCESS_READ
+	}
+	if rights&FILE_WRITE_DATA != 0 && (mode.IsDir() || mode&0200 != 0) {
+		access |= ACCESS_WRITE
+	}
+	if rights&FILE_EXECUTE != 0 {
+		access |= ACCESS_EXECUTE
+	}
+	return access
+}
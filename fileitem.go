@@ -51,7 +51,7 @@ func main() {
 	f, _ := os.Stat("test")
 	t := f.Sys().(*syscall.Stat_t).Birthtimespec
 	d := time.Unix(t.Sec, t.Nsec)
-	fmt.Println(d)
+	fmt.Fprintln(output, d)
 }
 */
 
@@ -59,6 +59,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -68,23 +69,38 @@ import (
 
 // Our basic list unit.
 type fileitem struct {
-	Path      string // Path to file, not including name
-	Name      string // Name including any extention
-	Size      int64
-	Modified  time.Time
-	Created   time.Time // If supported by the OS, this is when added. Otherwise 0 (time.Time{})
-	Accessed  time.Time // If supported by the OS, this is when added. Otherwise 0 (time.Time{})
-	IsDir     bool
-	Mode      fs.FileMode
-	LinkDest  string
-	InArchive bool
-	_ft       Filetype // Holds the filetype once initialized.  Use .FileType() instead.
+	Path       string // Path to file, not including name
+	Name       string // Name including any extention
+	Size       int64
+	Modified   time.Time
+	Created    time.Time // If supported by the OS, this is when added. Otherwise 0 (time.Time{})
+	Accessed   time.Time // If supported by the OS, this is when added. Otherwise 0 (time.Time{})
+	Changed    time.Time // Inode change time (ctime), on Unix.  Otherwise 0
+	IsDir      bool
+	Mode       fs.FileMode
+	LinkDest   string
+	InArchive  bool
+	Owner      string // Only filled in if owner_needed
+	Group      string
+	Links      uint64    // Hard link count, where supported
+	ID         fileid    // Only filled in if fileIDsNeeded()
+	Origin     string    // For -trashcan, where the file was deleted from
+	Deleted    time.Time // For -trashcan, when
+	Matches    int       // Content search matches; just 1 unless counting them
+	Compressed int64     // Archive members: size in the archive, or -1 if it can't be told
+	Unreadable string    // Why an archive couldn't be read, with -z
+	Patterns   []string  // -tf patterns found in it
+	Shared     int64     // -clones: bytes in extents shared with other files, -1 if unknown
+	Row        int       // -number: its number in the listing
+	_ft        Filetype  // Holds the filetype once initialized.  Use .FileType() instead.
+	_matched   bool      // Met fileMeetsFilters when its directory was read
+	_color     string    // From a .dir.conf, if it sets the color for the type
 }
 
 // BSD often has executable archives.  Weird concept, throws the basics off.
 // So we need more granularity.
 func (f fileitem) IsArchive() bool {
-	return strings.Contains(Extensions[ARCHIVE], ","+strings.ToLower(f.Extension()+","))
+	return f.Extension() != "" && archiveHandlerFor(f.Name) != nil // One that can be opened, whatever the types say
 }
 
 // Returns the extension based file type, or DIR/SYMLINK/EXE if appropriate.
@@ -97,13 +113,8 @@ func (f *fileitem) FileType() Filetype {
 		f._ft = DIRECTORY
 	} else if f.Mode&0111 != 0 { // i.e. any executable bit set
 		f._ft = EXECUTABLE
-	} else {
-		for ft := AUDIO; ft <= CODE; ft++ {
-			if strings.Contains(Extensions[ft], ","+strings.ToLower(f.Extension()+",")) {
-				f._ft = ft
-				break
-			}
-		}
+	} else if ft, found := typeForName(f.Name); found {
+		f._ft = ft
 	}
 	// Hidden comes last, because it's less important than others for colors.
 	if f._ft == NONE && f.Name[0] == '.' {
@@ -121,6 +132,23 @@ func (f fileitem) Extension() string {
 	return ternaryString(lastdot <= 1, "", strings.ToUpper(f.Name[lastdot+1:]))
 }
 
+// Times for the date columns.  Sub-second precision is shown with -precision.  ls style is "Jan _2 15:04", or with the year instead of the time
+// if more than six months from now, like ls -l.
+func formatTime(t time.Time) string {
+	if ls_dates {
+		if t.Before(time.Now().AddDate(0, -6, 0)) || t.After(time.Now().AddDate(0, 6, 0)) {
+			return t.Format("Jan _2  2006")
+		}
+		return t.Format("Jan _2 15:04")
+	}
+	return t.Format(timeLayout())
+}
+
+// The layout of formatTime, with fractions of a second if -precision asks.
+func timeLayout() string {
+	return "2006-01-02 15:04:05" + map[string]string{"ms": ".000", "us": ".000000", "ns": ".000000000"}[time_precision]
+}
+
 func FileSizeToString(fSize int64) string {
 	switch filesizes_format {
 	case SIZE_QUANTA:
@@ -175,12 +203,127 @@ func (f fileitem) ModeToString() string {
 	return rwx.String()
 }
 
-// The settings for this are global, in dir.go.
-func (f fileitem) ToString() string {
-	name := f.Name
+// What the current user can do with it, as ACCESS_ bits.  Archive members go by the archive.
+func (f fileitem) EffectiveAccess() int {
+	if f.InArchive {
+		return effectiveAccess(f.Path, f.Mode)
+	}
+	return effectiveAccess(filepath.Join(f.Path, f.Name), f.Mode)
+}
+
+// EffectiveAccess as rwx, for the e column.
+func (f fileitem) EffectiveRights() string {
+	access := f.EffectiveAccess()
+	return ternaryString(access&ACCESS_READ != 0, "r", "-") + ternaryString(access&ACCESS_WRITE != 0, "w", "-") +
+		ternaryString(access&ACCESS_EXECUTE != 0, "x", "-")
+}
+
+// Whole days since the file was modified, or created or accessed with -age=.  Files without the
+// time fall back to modified.
+func (f fileitem) AgeDays() int {
+	t := f.Modified
+	if age_from == "c" && !f.Created.IsZero() {
+		t = f.Created
+	} else if age_from == "a" && !f.Accessed.IsZero() {
+		t = f.Accessed
+	}
+	return int(time.Since(t).Hours() / 24)
+}
+
+// Bytes taken in the archive for members, -1 if unknown, as with 7z and tgz.  Files on disk are
+// their size.
+func (f fileitem) CompressedSize() int64 {
+	if f.InArchive {
+		return f.Compressed
+	}
+	return f.Size
+}
+
+// Number of files in an archive and their total size, for the u column.  Blank for anything else.
+func (f fileitem) ArchiveMembers() string {
+	width := 7 + len(FileSizeToString(0))
+	if f.InArchive || f.IsDir || archiveHandlerFor(f.Name) == nil {
+		return fmt.Sprintf("%*s", width, "")
+	}
+	members, size, err := archiveMemberTotals(filepath.Join(f.Path, f.Name))
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read %s: %s\n", f.Name, err.Error())
+		return fmt.Sprintf("%-*s", width, "   ?")
+	}
+	return fmt.Sprintf("%6d %s", members, FileSizeToString(size))
+}
+
+// Name as printed: with the path for b+, relative to the start directory for -files-from.
+func (f fileitem) DisplayName() string {
+	if relative_paths {
+		if rel, err := filepath.Rel(start_directory, filepath.Join(f.Path, f.Name)); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
 	if include_path {
-		name = filepath.Join(f.Path, f.Name)
+		return displayPath(filepath.Join(f.Path, f.Name))
+	}
+	return f.Name
+}
+
+// Rewrites the start of a path for display, with -prefix-strip and -prefix-add: e.g. a staging
+// mount point to where the files will be deployed, or a URL.  Paths that aren't under the
+// stripped prefix - /tmp/t2 isn't under /tmp/t - are left alone.
+func displayPath(path string) string {
+	if len(prefix_strip) > 0 {
+		rest, found := strings.CutPrefix(path, prefix_strip)
+		if !found || (len(rest) > 0 && !isSeparator(rest[0]) && !isSeparator(prefix_strip[len(prefix_strip)-1])) {
+			return path
+		}
+		path = rest
 	}
+	if len(prefix_add) > 0 && len(path) > 0 && isSeparator(prefix_add[len(prefix_add)-1]) && isSeparator(path[0]) {
+		path = path[1:] // One slash between them, not two
+	}
+	return prefix_add + path
+}
+
+// / or the OS's separator.
+func isSeparator(c byte) bool {
+	return c == '/' || c == os.PathSeparator
+}
+
+// The number of entries in a directory, hidden ones included, or -1 if it can't be read.
+// Archive members can't be.
+func (f fileitem) EntryCount() int64 {
+	if !f.IsDir || f.InArchive {
+		return -1
+	}
+	dir, err := os.Open(filepath.Join(f.Path, f.Name))
+	if err != nil {
+		return -1
+	}
+	defer dir.Close()
+	var count int64
+	for {
+		names, err := dir.Readdirnames(1024)
+		count += int64(len(names))
+		if err == io.EOF {
+			return count
+		} else if err != nil {
+			return -1
+		}
+	}
+}
+
+// The archive an archive member is in, or "" for files on disk.
+func (f fileitem) Container() string {
+	return ternaryString(f.InArchive, displayPath(f.Path), "")
+}
+
+// The path of an archive member within its archive, or "" for files on disk.
+func (f fileitem) MemberPath() string {
+	return ternaryString(f.InArchive, f.Name, "")
+}
+
+// The settings for this are global, in dir.go.
+func (f fileitem) ToString() string {
+	name := f.DisplayName()
 	if bare {
 		return name
 	}
@@ -189,83 +332,177 @@ func (f fileitem) ToString() string {
 	linktext := ternaryString(len(f.LinkDest) > 0, "-> "+f.LinkDest, "")
 
 	if use_colors {
-		colorstr = colorSetString(f.FileType())
-		if !use_enhanced_colors && f.FileType() >= DOCUMENT && f.FileType() < DIRECTORY {
-			colorstr = colorSetString(DEFAULT) // Because not enhanced.
-		}
+		colorstr = f.colorString()
 		colorreset = colorSetString(NONE)
 	}
 	createdTime := ""
 	if !f.Created.IsZero() {
-		createdTime = f.Created.Format("  (2006-01-02 15:04:05)")
+		createdTime = "  (" + formatTime(f.Created) + ")"
 	}
-	return fmt.Sprintf("%s%s   %s%s  %s   %s%s%s", colorstr, f.ModeToString(), f.Modified.Format("2006-01-02 15:04:05"), createdTime, f.FileSizeToString(), name, linktext, colorreset)
+	return fmt.Sprintf("%s%s   %s%s  %s   %s%s%s", colorstr, f.ModeToString(), formatTime(f.Modified), createdTime, f.FileSizeToString(), name, linktext, colorreset)
 }
 
 // Set off of the columns map
 func (f fileitem) BuildOutput() string {
-	name := f.Name
-	if include_path {
-		name = filepath.Join(f.Path, f.Name)
-	}
+	return f.buildOutput(columnSpecs(), nil)
+}
+
+// The line for the file with the given columns, using any values already worked out.
+func (f fileitem) buildOutput(specs []columnSpec, known map[byte]string) string {
 	if bare {
-		return name
+		return f.DisplayName()
+	}
+	value := func(column byte) string {
+		if v, found := known[column]; found {
+			return v
+		}
+		return f.ColumnValue(column)
+	}
+	if len(field_separator) > 0 { // Parseable: just the columns, no literals, padding or colors.
+		var fields []string
+		for _, spec := range specs {
+			if spec.column != 0 {
+				fields = append(fields, strings.TrimSpace(value(spec.column)))
+			}
+		}
+		return strings.Join(fields, field_separator)
 	}
 	colorstr := ""
 	colorreset := ""
-	linktext := ternaryString(len(f.LinkDest) > 0, "-> "+f.LinkDest, "")
-
 	if use_colors {
-		colorstr = colorSetString(f.FileType())
-		if !use_enhanced_colors && f.FileType() >= DOCUMENT && f.FileType() < DIRECTORY {
-			colorstr = colorSetString(DEFAULT) // Because not enhanced.
-		}
+		colorstr = f.colorString()
 		colorreset = colorSetString(NONE)
 	}
-	modifiedTime := f.Modified.Format("2006-01-02 15:04:05")
-	accessedTime := ""
-	if !f.Accessed.IsZero() {
-		accessedTime = f.Accessed.Format("2006-01-02 15:04:05")
-	}
-	createdTime := ""
-	if !f.Created.IsZero() {
-		createdTime = f.Created.Format("2006-01-02 15:04:05")
-	}
 	outputString := colorstr
-	for i := 0; i < len(columnDef); i++ { //run a loop and iterate through each character
-		switch string(columnDef[i]) {
-		case COLUMN_DATEMODIFIED:
-			outputString += modifiedTime
-		case COLUMN_DATECREATED:
-			outputString += createdTime
-		case COLUMN_DATEACCESSED:
-			outputString += accessedTime
-		case COLUMN_FILESIZE:
-			outputString += f.FileSizeToString()
-		case COLUMN_MODE:
-			outputString += f.ModeToString()
-		case COLUMN_NAME:
-			outputString += name
-		case COLUMN_LINK:
-			outputString += linktext
-		default:
-			outputString += string(columnDef[i])
+	for _, spec := range specs {
+		if spec.column == 0 {
+			outputString += spec.literal
+		} else if sizecolor := f.sizeColorString(spec.column); len(sizecolor) > 0 {
+			outputString += sizecolor + spec.fit(value(spec.column)) + colorreset + colorstr
+		} else {
+			outputString += spec.fit(value(spec.column))
 		}
 	}
 	outputString += colorreset
 	return outputString
 }
 
+// The text for one column letter of -c=.  Returns "" for unknown letters.
+func (f fileitem) ColumnValue(column byte) string {
+	switch string(column) {
+	case COLUMN_DATEMODIFIED:
+		return formatTime(f.Modified)
+	case COLUMN_DATECREATED:
+		if !f.Created.IsZero() {
+			return formatTime(f.Created)
+		}
+	case COLUMN_DATEACCESSED:
+		if !f.Accessed.IsZero() {
+			return formatTime(f.Accessed)
+		}
+	case COLUMN_DATECHANGED:
+		if !f.Changed.IsZero() {
+			return formatTime(f.Changed)
+		}
+	case COLUMN_FILESIZE:
+		return f.FileSizeToString()
+	case COLUMN_MODE:
+		return f.ModeToString()
+	case COLUMN_NAME:
+		if len(f.Unreadable) > 0 {
+			return f.DisplayName() + "  !! UNREADABLE: " + f.Unreadable
+		}
+		return f.DisplayName()
+	case COLUMN_LINK:
+		return ternaryString(len(f.LinkDest) > 0, "-> "+f.LinkDest, "")
+	case COLUMN_VERSION:
+		return fmt.Sprintf("%-25s", f.VersionInfo())
+	case COLUMN_BINARY:
+		return fmt.Sprintf("%-22s", f.BinaryInfo())
+	case COLUMN_SIGNATURE:
+		return fmt.Sprintf("%-10s", f.SignatureStatus())
+	case COLUMN_CHECKSUM:
+		return fmt.Sprintf("%-4s", f.SidecarStatus())
+	case COLUMN_CHANGE:
+		return fmt.Sprintf("%-1s", f.ChangeStatus())
+	case COLUMN_ORIGIN:
+		return f.Origin
+	case COLUMN_DELETED:
+		if !f.Deleted.IsZero() {
+			return formatTime(f.Deleted)
+		}
+		return fmt.Sprintf("%*s", len(timeLayout()), "")
+	case COLUMN_FILESYSTEM:
+		return fmt.Sprintf("%-6s", f.FileSystem())
+	case COLUMN_LINKS:
+		return fmt.Sprintf("%3d", f.Links)
+	case COLUMN_OWNER:
+		return fmt.Sprintf("%-8s", f.Owner)
+	case COLUMN_GROUP:
+		return fmt.Sprintf("%-8s", f.Group)
+	case COLUMN_MATCHES:
+		return fmt.Sprintf("%5d", f.Matches)
+	case COLUMN_EFFECTIVE:
+		return f.EffectiveRights()
+	case COLUMN_AGE:
+		return fmt.Sprintf("%5d", f.AgeDays())
+	case COLUMN_CHAIN:
+		return f.LinkChain()
+	case COLUMN_PATTERNS:
+		return strings.Join(f.Patterns, ", ")
+	case COLUMN_MEMBERS:
+		return f.ArchiveMembers()
+	case COLUMN_SHARED:
+		return f.SharedString()
+	case COLUMN_ROW:
+		return f.RowNumber()
+	case COLUMN_OFFICE:
+		return fmt.Sprintf("%-10s", f.OfficeInfo())
+	case COLUMN_LANGUAGE:
+		return fmt.Sprintf("%-10s", f.Language())
+	case COLUMN_SENSITIVE:
+		if kind := f.SensitiveKind(); len(kind) > 0 {
+			return fmt.Sprintf("!%-10s", kind)
+		}
+		return fmt.Sprintf("%-11s", "")
+	case COLUMN_CONTAINER:
+		return f.Container()
+	case COLUMN_MEMBER:
+		return f.MemberPath()
+	case COLUMN_COMPRESSED:
+		if c := f.CompressedSize(); c >= 0 {
+			return FileSizeToString(c)
+		}
+		return fmt.Sprintf("%*s", len(FileSizeToString(0)), "")
+	default:
+		if tmpl, found := customColumns[column]; found {
+			return f.CustomColumn(tmpl)
+		}
+	}
+	return ""
+}
+
 func makefileitem(de fs.DirEntry, path string) fileitem {
 	var item fileitem
 	link, _ := os.Readlink(filepath.Join(path, de.Name()))
 	fi, e := de.Info()
 	if e == nil {
-		item = fileitem{path, fi.Name(), fi.Size(), fi.ModTime(), time.Time{}, time.Time{}, fi.IsDir(), fi.Mode(), link, false, NONE}
+		item = fileitem{Path: path, Name: fi.Name(), Size: fi.Size(), Modified: fi.ModTime(), IsDir: fi.IsDir(), Mode: fi.Mode(), LinkDest: link}
 		// Only do this on supported system. https://go.dev/doc/install/source#environment  $GOOS == android, darwin, dragonfly, freebsd, illumos, ios, js, linux, netbsd, openbsd, plan9, solaris, wasip1, and windows.
 		// If checking for create time, try to fill in here.
 		// Possible elements: Birthtimespec,
 		item.Created, item.Accessed = createdAndAccessed(fi)
+		item.Changed = changedTime(fi)
+		item.Links = linkCount(fi)
+		if fileIDsNeeded() {
+			item.ID, item.Links = fileID(filepath.Join(path, de.Name()), fi)
+		}
+		if entry, found := trashInfo[fi.Name()]; found && path == trashRoot {
+			item.Origin, item.Deleted = entry.origin, entry.deleted
+		}
+		if owner_needed {
+			item.Owner, item.Group = ownerAndGroup(filepath.Join(path, de.Name()), fi)
+		}
 	}
 	return item
 }
@@ -0,0 +1,170 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Flag names and syntax.  Besides -name=value, the usual forms are taken: --name, -name value and
+// single letters run together, as -rb.  Unknown flags are an error, with the nearest known ones
+// suggested, as a typo otherwise goes unnoticed: dir -r -mz=1G lists everything.  -lenient
+// ignores them instead, for scripts that may meet an older dir.
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	lenient       bool     // -lenient
+	unknown_flags []string // As given, reported once all the flags are read
+)
+
+// The flags parseCmdLine knows, for suggestions.  One missing here is still accepted; it just
+// won't be suggested.
+var flagNames = []string{
+	"h", "help", "on", "o-n", "od", "o-d", "oc", "o-c", "oa", "o-a", "oi", "o-i", "ox", "o-x", "ot", "o-t", "os",
+	"o-s", "oz", "o-z", "ow", "o-w", "ou", "o-u", "or", "o-r", "tie", "group", "helper-timeout",
+	"helper-max-output", "mounts", "pdf-helper", "hardlinks", "count-links-once", "histogram", "week-start",
+	"fiscal", "archive-ratio", "archive-members", "archive-depth", "archive-time", "ah-", "ah+", "ar+", "ar-",
+	"perm", "readable", "readable+", "readable-", "writable", "writable+", "writable-", "executable",
+	"executable+", "executable-", "za", "zhas", "dirs-mixed", "pause", "cs", "b+", "b", "fast", "files-from",
+	"c", "d+", "d-", "debug", "error", "errors", "G-", "G", "G+", "l", "ma", "mc", "mi", "md", "ms",
+	"links-count", "prefix-strip", "prefix-add", "mine", "maxe", "mz", "r", "depth", "maxdepth", "prune", "sep",
+	"since", "snapshot", "sc", "sh", "sr", "t", "tc", "ti", "ts", "tr", "format", "all-matches", "show-contents",
+	"rollup", "precision", "age", "linkto", "recent", "search-window", "size-colors", "size-colors+",
+	"size-colors-", "wide", "w", "unbuffered", "explain", "audit", "sensitive", "nogenerated", "lang",
+	"language", "tt", "tw", "tm", "tf", "tfi", "tx", "timeout", "cache", "number", "pick", "smart-columns",
+	"first", "max", "watch", "interval", "maxtime", "retries", "batch", "exec", "copyto", "moveto", "extractto",
+	"trash", "dry-run", "confirm", "trashcan", "unordered", "clones", "verify-sidecars", "version", "v",
+	"dirignore", "dirignore+", "dirignore-", "dirconf", "dirconf+", "dirconf-", "exclude", "x", "xd", "z",
+	"archive-order", "lenient", "portable",
+}
+
+// Flags that must have a value, so may take it from the next argument, as -ms 1M:.  Those where
+// the value is optional, like -z or -recent, have to use =.
+var valueFlags = []string{
+	"tie", "group", "helper-timeout", "helper-max-output", "pdf-helper", "histogram", "week-start", "fiscal",
+	"archive-ratio", "archive-members", "archive-depth", "archive-time", "perm", "zhas", "c", "ma", "mc", "mi",
+	"md", "ms", "links-count", "prefix-strip", "prefix-add", "mine", "maxe", "mz", "depth", "maxdepth", "sep",
+	"since", "snapshot", "tc", "ti", "ts", "tr", "format", "precision", "age", "linkto", "search-window", "lang",
+	"language", "tt", "tf", "tfi", "tx", "timeout", "pick", "max", "interval", "maxtime", "retries", "batch",
+	"exec", "copyto", "moveto", "extractto", "exclude", "x", "xd", "archive-order",
+}
+
+// Rewrites the usual flag forms as -name or -name=value, for parseCmdLine: --name becomes -name,
+// -name value becomes -name=value for valueFlags, and -rb becomes -r -b, when the letters are each
+// flags without values.  Anything else is left as it is.
+func normalizeFlags(args []string) []string {
+	var normal []string
+	for i := 0; i < len(args); i++ {
+		s := args[i]
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(s, "-"), "=")
+		if strings.HasPrefix(s, "--") && slices.Contains(flagNames, name[1:]) {
+			s, name = s[1:], name[1:]
+		}
+		if !strings.HasPrefix(s, "-") || hasValue {
+			normal = append(normal, s)
+		} else if slices.Contains(valueFlags, name) && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			normal = append(normal, s+"="+args[i+1])
+			i++
+		} else if letters := splitLetterFlags(name); letters != nil {
+			normal = append(normal, letters...)
+		} else {
+			normal = append(normal, s)
+		}
+	}
+	return normal
+}
+
+// -rb as -r -b: each letter a flag, with a trailing + or - going with the last, as -rG-.  nil if
+// it's a known flag itself, or any letter isn't a flag or needs a value.
+func splitLetterFlags(name string) []string {
+	if len(name) < 2 || slices.Contains(flagNames, name) {
+		return nil
+	}
+	var flags []string
+	for i := 0; i < len(name); i++ {
+		flag := name[i : i+1]
+		if i == len(name)-2 && (name[i+1] == '+' || name[i+1] == '-') {
+			flag = name[i:]
+			i++
+		}
+		if !slices.Contains(flagNames, flag) || slices.Contains(valueFlags, flag) || flag == "h" || flag == "v" {
+			return nil
+		}
+		flags = append(flags, "-"+flag)
+	}
+	return flags
+}
+
+// Reports the unknown flags and exits, unless -lenient.
+func checkUnknownFlags() {
+	if lenient {
+		for _, f := range unknown_flags {
+			conditionalPrint(debug_messages, "Ignoring unknown flag %s\n", f)
+		}
+		return
+	}
+	if len(unknown_flags) == 0 {
+		return
+	}
+	for _, f := range unknown_flags {
+		name := strings.TrimLeft(strings.SplitN(f, "=", 2)[0], "-/")
+		if suggestions := similarFlags(name); len(suggestions) > 0 {
+			conditionalPrint(true, "Unknown flag %s.  Did you mean -%s?\n", f, strings.Join(suggestions, " or -"))
+		} else {
+			conditionalPrint(true, "Unknown flag %s.\n", f)
+		}
+	}
+	fmt.Fprintf(output, "See dir -help, or use -lenient to ignore unknown flags.\n")
+	os.Exit(1)
+}
+
+// The known flags nearest to name, by edit distance, at most three.  Short names only get
+// suggestions one edit away, as everything is two edits from a one letter flag.
+func similarFlags(name string) []string {
+	best := ternaryInt(len(name) <= 3, 2, 3) // Beyond this isn't similar
+	var similar []string
+	for _, f := range flagNames {
+		switch d := editDistance(strings.ToLower(name), f); {
+		case d < best:
+			best, similar = d, []string{f}
+		case d == best && len(similar) > 0 && len(similar) < 3:
+			similar = append(similar, f)
+		}
+	}
+	return similar
+}
+
+// Levenshtein distance: the insertions, deletions and substitutions to turn a into b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := ternaryInt(a[i-1] == b[j-1], 0, 1)
+			current[j] = ternaryInt(previous[j]+1 < current[j-1]+1, previous[j]+1, current[j-1]+1)
+			current[j] = ternaryInt(previous[j-1]+cost < current[j], previous[j-1]+cost, current[j])
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -nogenerated: leaves out files a tool wrote rather than a person - minified scripts, generated
+// code, source maps and lockfiles - so searches of a codebase find what was written by hand.
+// The config file's generated setting adds patterns.
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+var (
+	skip_generated     bool // -nogenerated
+	generated_patterns = []string{"*.min.js", "*.min.css", "*.map", "*.pb.go", "*.pb.cc", "*.pb.h", "*_pb2.py",
+		"*_pb2_grpc.py", "*.g.dart", "*.g.cs", "*.designer.cs", "*.generated.*", "*_generated.go", "zz_generated*",
+		"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock",
+		"Gemfile.lock", "composer.lock", "poetry.lock", "Pipfile.lock", "go.sum", "flake.lock"}
+	generatedMatchers []glob.Glob
+	goGeneratedHeader = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`) // As go generate tools write
+)
+
+const goHeaderBytes = 4096 // Of a .go file, looked at for the header
+
+// Adds comma separated patterns, from the config file or -nogenerated=.
+func addGeneratedPatterns(value string) {
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); len(pattern) > 0 {
+			generated_patterns = append(generated_patterns, pattern)
+		}
+	}
+}
+
+// Compiles the patterns, once all are known.  Names are matched regardless of case.
+func compileGeneratedPatterns() {
+	for _, pattern := range generated_patterns {
+		if matcher, err := glob.Compile(strings.ToUpper(pattern)); err == nil {
+			generatedMatchers = append(generatedMatchers, matcher)
+		} else {
+			conditionalPrint(show_errors, "Invalid generated pattern %s: %s\n", pattern, err.Error())
+		}
+	}
+}
+
+func isGenerated(f *fileitem) bool {
+	if f.IsDir {
+		return false
+	}
+	name := strings.ToUpper(f.Name)
+	for _, matcher := range generatedMatchers {
+		if matcher.Match(name) {
+			return true
+		}
+	}
+	return !f.InArchive && strings.HasSuffix(name, ".GO") && hasGoGeneratedHeader(filepath.Join(f.Path, f.Name))
+}
+
+// Go code from stringer and the like is marked at the top, whatever its name.
+func hasGoGeneratedHeader(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	head, _ := io.ReadAll(io.LimitReader(file, goHeaderBytes))
+	return goGeneratedHeader.Match(head)
+}
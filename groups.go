@@ -0,0 +1,279 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Reports over everything matched, rather than per directory: -group= sections and -histogram=.
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GROUP_EXT   = "ext"
+	GROUP_TYPE  = "type"
+	GROUP_DIR   = "dir"
+	GROUP_DATE  = "date"
+	GROUP_OWNER = "owner"
+	GROUP_LANG  = "lang"
+)
+
+// Date periods, for -group= and -histogram=.  Weeks begin on week_start, and quarters and years
+// on fiscal_start.
+var datePeriods = []string{"day", "week", "month", "quarter", "year"}
+
+const histogramWidth = 50 // Characters in the longest bar
+
+var (
+	group_by       string // One of the GROUP_ values, or "" for the usual per-directory listing
+	histogram_by   string // One of datePeriods
+	date_group     string // The period -group= sections by: one of datePeriods, or "" if not by date
+	week_start     = time.Monday
+	fiscal_start   = time.January // First month of the year, for quarters and years
+	collectedFiles []fileitem     // Everything matched, held until the walk is done
+)
+
+// True if files are held for a report at the end, rather than listed by directory.
+func collectingFiles() bool {
+	return len(group_by) > 0 || len(histogram_by) > 0 || hardlink_report || output_json || recent || rollup || audit_secrets
+}
+
+// Returns the label for f's section, and a key that orders the sections.
+func groupOf(f *fileitem) (string, string) {
+	switch group_by {
+	case GROUP_EXT:
+		ext := f.Extension()
+		if f.IsDir {
+			return "Directories", ""
+		}
+		return ternaryString(len(ext) > 0, ext, "(no extension)"), "." + ext
+	case GROUP_TYPE: // Same classification and order as -ot
+		return f.FileType().String(), fmt.Sprintf("%02d", FileTypeSortOrder[f.FileType()])
+	case GROUP_DIR:
+		return displayPath(f.Path), f.Path
+	case GROUP_DATE:
+		label, start := datePeriod(f.Modified, date_group)
+		return label, start.Format("2006-01-02")
+	case GROUP_OWNER:
+		return ternaryString(len(f.Owner) > 0, f.Owner, "(unknown owner)"), f.Owner
+	case GROUP_LANG: // Code by language, then everything else
+		if f.IsDir {
+			return "Directories", "2"
+		}
+		if language := f.Language(); len(language) > 0 {
+			return language, "0" + language
+		}
+		return "(not code)", "1"
+	}
+	return "", ""
+}
+
+// Sorts the files into sections, keeping the -o order within each, and prints them.
+func printGroups(files []fileitem) {
+	sortFiles(files)
+	sort.SliceStable(files, func(i, j int) bool {
+		_, first := groupOf(&files[i])
+		_, second := groupOf(&files[j])
+		return first < second
+	})
+	for start := 0; start < len(files); {
+		label, key := groupOf(&files[start])
+		end, filecount, dircount, bytes := start, 0, 0, int64(0)
+		for ; end < len(files); end++ {
+			if _, k := groupOf(&files[end]); k != key {
+				break
+			}
+			if files[end].IsDir {
+				dircount++
+			} else {
+				filecount++
+				bytes += files[end].Size
+			}
+		}
+		if directory_header {
+			fmt.Fprintf(output, "\n   %s\n", label)
+			if listfiles && !totals_only {
+				fmt.Fprintf(output, "\n")
+			}
+		}
+		printFiles(files[start:end])
+		if size_calculations {
+			fmt.Fprintf(output, "   %4d Files (%s bytes) and %4d Directories.\n", filecount, FileSizeToString(bytes), dircount)
+		}
+		start = end
+	}
+}
+
+// Validates the -group= value.  Dates may be by any of the periods, e.g. -group=week.
+func parseGroupBy(value string) string {
+	value = strings.ToLower(value)
+	if slices.Contains(datePeriods, value) || value == GROUP_DATE {
+		date_group = ternaryString(value == GROUP_DATE, "day", value)
+		return GROUP_DATE
+	}
+	switch value {
+	case GROUP_EXT, GROUP_TYPE, GROUP_DIR, GROUP_OWNER, GROUP_LANG:
+		return value
+	case "x", "extension":
+		return GROUP_EXT
+	case "t":
+		return GROUP_TYPE
+	case "d", "directory":
+		return GROUP_DIR
+	case "o", "user":
+		return GROUP_OWNER
+	case "y", "language":
+		return GROUP_LANG
+	}
+	conditionalPrint(show_errors, "Unknown group %s; use ext, type, dir, owner, lang, date, week, month, quarter or year.\n", value)
+	return ""
+}
+
+// The period t falls in: a label for it, and when it began.  Fiscal years are named for the
+// calendar year they end in, so with -fiscal=oct, October 2023 is in FY2024 Q1.
+func datePeriod(t time.Time, period string) (string, time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	fiscalYear := func(start time.Time) int {
+		return ternaryInt(fiscal_start == time.January, start.Year(), start.Year()+1)
+	}
+	yearLabel := ternaryString(fiscal_start == time.January, "%d", "FY%d")
+	switch period {
+	case "week":
+		start := day.AddDate(0, 0, -((int(day.Weekday()) - int(week_start) + 7) % 7))
+		return start.Format("2006-01-02"), start
+	case "month":
+		start := day.AddDate(0, 0, 1-day.Day())
+		return start.Format("2006-01"), start
+	case "quarter", "year":
+		months := ternaryInt(period == "quarter", 3, 12)
+		offset := (int(day.Month()) - int(fiscal_start) + 12) % 12
+		start := time.Date(day.Year(), day.Month()-time.Month(offset%months), 1, 0, 0, 0, 0, day.Location())
+		yearStart := time.Date(day.Year(), day.Month()-time.Month(offset), 1, 0, 0, 0, 0, day.Location())
+		label := fmt.Sprintf(yearLabel, fiscalYear(yearStart))
+		if period == "quarter" {
+			label += fmt.Sprintf(" Q%d", offset/3+1)
+		}
+		return label, start
+	}
+	return day.Format("2006-01-02"), day
+}
+
+// The start of the period after the one beginning at start.
+func nextPeriod(start time.Time, period string) time.Time {
+	switch period {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	case "quarter":
+		return start.AddDate(0, 3, 0)
+	case "year":
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Counts files by modification period and draws a bar for each, scaled to the largest count.
+// Periods longer than a day with nothing in them are shown, so gaps stand out.
+func printHistogram(files []fileitem) {
+	counts := map[time.Time]int{}
+	bytes := map[time.Time]int64{}
+	labels := map[time.Time]string{}
+	var first, last time.Time
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		label, bucket := datePeriod(f.Modified, histogram_by)
+		counts[bucket]++
+		bytes[bucket] += f.Size
+		labels[bucket] = label
+		if first.IsZero() || bucket.Before(first) {
+			first = bucket
+		}
+		if bucket.After(last) {
+			last = bucket
+		}
+	}
+	var buckets []time.Time
+	if histogram_by == "day" {
+		for bucket := range counts {
+			buckets = append(buckets, bucket)
+		}
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Before(buckets[j]) })
+	} else {
+		for t := first; len(counts) > 0 && !t.After(last); t = nextPeriod(t, histogram_by) {
+			if _, found := labels[t]; !found {
+				labels[t], _ = datePeriod(t, histogram_by)
+			}
+			buckets = append(buckets, t)
+		}
+	}
+	most := 1
+	for _, n := range counts {
+		if n > most {
+			most = n
+		}
+	}
+	if directory_header {
+		fmt.Fprintf(output, "\n   Files modified by %s in %s\n\n", histogram_by, start_directory)
+	}
+	for _, bucket := range buckets {
+		bar := strings.Repeat("#", (counts[bucket]*histogramWidth+most-1)/most)
+		fmt.Fprintf(output, "   %-10s %6d  %s  %s\n", labels[bucket], counts[bucket], FileSizeToString(bytes[bucket]), bar)
+	}
+}
+
+// Validates the -histogram= value.
+func parseHistogram(value string) string {
+	value = strings.ToLower(value)
+	if slices.Contains(datePeriods, value) {
+		return value
+	}
+	conditionalPrint(show_errors, "Unknown histogram %s; use day, week, month, quarter or year.\n", value)
+	return ""
+}
+
+// Parses -week-start=, a day name such as sun or monday.
+func parseWeekStart(value string) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if len(value) >= 2 && strings.HasPrefix(strings.ToLower(day.String()), strings.ToLower(value)) {
+			week_start = day
+			return
+		}
+	}
+	conditionalPrint(show_errors, "Unknown -week-start=%s; use a day such as sun or mon.\n", value)
+}
+
+// Parses -fiscal=, the month the fiscal year begins, as a name such as apr or a number 1-12.
+func parseFiscalStart(value string) {
+	if n, err := strconv.Atoi(value); err == nil && n >= 1 && n <= 12 {
+		fiscal_start = time.Month(n)
+		return
+	}
+	for month := time.January; month <= time.December; month++ {
+		if len(value) >= 3 && strings.HasPrefix(strings.ToLower(month.String()), strings.ToLower(value)) {
+			fiscal_start = month
+			return
+		}
+	}
+	conditionalPrint(show_errors, "Unknown -fiscal=%s; use a month such as apr or 4.\n", value)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Hard links: -hardlinks reports which listed paths share data, and -count-links-once counts
+// that data once in the totals.
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Identifies a file's data: device and inode, or volume serial number and file index on Windows.
+type fileid struct {
+	volume uint64
+	index  uint64
+}
+
+var (
+	hardlink_report  bool // -hardlinks
+	count_links_once bool // -count-links-once
+	countedIDs       = map[fileid]bool{}
+)
+
+// Also for -links-count, as Windows only counts links when it looks up the file ID.
+func fileIDsNeeded() bool {
+	return hardlink_report || count_links_once || linkCountFilter()
+}
+
+// True if -links-count was given.
+func linkCountFilter() bool {
+	return min_links >= 0 || max_links < math.MaxInt64
+}
+
+// True if this file's data has already been counted in the totals, with -count-links-once.
+func countedBefore(f fileitem) bool {
+	if !count_links_once || f.Links < 2 || f.ID == (fileid{}) {
+		return false
+	}
+	if countedIDs[f.ID] {
+		return true
+	}
+	countedIDs[f.ID] = true
+	return false
+}
+
+// Prints each set of listed files with more than one link, noting links that weren't listed.
+func printHardLinks(files []fileitem) {
+	clusters := map[fileid][]fileitem{}
+	for _, f := range files {
+		if !f.IsDir && f.Links > 1 && f.ID != (fileid{}) {
+			clusters[f.ID] = append(clusters[f.ID], f)
+		}
+	}
+	var ids []fileid
+	for id, cluster := range clusters {
+		sort.Slice(cluster, func(i, j int) bool {
+			return filepath.Join(cluster[i].Path, cluster[i].Name) < filepath.Join(cluster[j].Path, cluster[j].Name)
+		})
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		first, second := clusters[ids[i]][0], clusters[ids[j]][0]
+		return filepath.Join(first.Path, first.Name) < filepath.Join(second.Path, second.Name)
+	})
+	if directory_header {
+		fmt.Fprintf(output, "\n   Hard links in %s\n", start_directory)
+	}
+	var shared int64
+	for _, id := range ids {
+		cluster := clusters[id]
+		if directory_header {
+			fmt.Fprintf(output, "\n   %d links, %s bytes", cluster[0].Links, strings.TrimSpace(FileSizeToString(cluster[0].Size)))
+			if uint64(len(cluster)) < cluster[0].Links {
+				fmt.Fprintf(output, ", %d not listed", cluster[0].Links-uint64(len(cluster)))
+			}
+			fmt.Fprintf(output, ":\n")
+		}
+		for _, f := range cluster {
+			fmt.Fprintln(output, ternaryString(bare, "", "      ")+displayPath(filepath.Join(f.Path, f.Name)))
+		}
+		shared += cluster[0].Size * int64(len(cluster)-1)
+	}
+	if size_calculations {
+		fmt.Fprintf(output, "\n   %4d sets of links, with %s bytes listed more than once.\n", len(ids), strings.TrimSpace(FileSizeToString(shared)))
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Running external helpers, like pdftotext, with a time limit and a cap on their output, so one
+// malformed file can't stall a recursive search.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A program that can extract the text of a PDF.
+type pdfHelper struct {
+	name      string   // Program name, without any .exe
+	args      []string // {} is replaced by the PDF's path
+	pageFiles bool     // Writes a file.N.txt per page beside the PDF, instead of to stdout
+}
+
+// Tried in this order.  (sips, on macOS, can render a PDF but can't extract its text.)
+var pdfHelpers = []pdfHelper{
+	{"pdftotext", []string{"{}", "-"}, false},
+	{"mutool", []string{"draw", "-F", "txt", "-o", "-", "{}"}, false},
+	{"pdfium_test", []string{"--txt", "{}"}, true},
+}
+
+var (
+	helper_timeout    time.Duration = 30 * time.Second // Per file
+	helper_max_output int64         = 64 << 20         // Bytes kept from stdout; the helper is stopped past this
+	pdf_helper        string                           // Name or path of the PDF helper, if not found automatically
+	errHelperTimeout  = errors.New("timed out")
+)
+
+// Keeps the first limit bytes written, then cancels the helper.  Not an embedded bytes.Buffer,
+// as io.Copy would use its ReadFrom and bypass the limit.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int64
+	truncated bool
+	cancel    context.CancelFunc
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - int64(b.buf.Len())
+	if int64(len(p)) > room {
+		b.buf.Write(p[:room])
+		if !b.truncated {
+			b.truncated = true
+			b.cancel()
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Runs a helper program on a file and returns its output.  Output past helper_max_output is
+// dropped, but what came before is returned.  Time-outs are always reported, as the file
+// was not really searched.
+func runHelper(program string, file string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if helper_timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), helper_timeout)
+	}
+	defer cancel()
+	stdout := cappedBuffer{limit: helper_max_output, cancel: cancel}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, program, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // In case the helper's own children hold its output open
+	err := cmd.Run()
+	if stdout.truncated {
+		conditionalPrint(debug_messages, "%s output on %s truncated at %d bytes\n", filepath.Base(program), file, helper_max_output)
+		return stdout.buf.Bytes(), nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		conditionalPrint(!bare || show_errors, "%s timed out after %s on %s\n", filepath.Base(program), helper_timeout, file)
+		return nil, errHelperTimeout
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stderr.Len() > 0 {
+		conditionalPrint(show_errors, "%s reported errors on %s: %s", filepath.Base(program), file, stderr.String())
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// Finds the PDF helper: the one named by -pdf-helper= or the config, else the first of
+// pdfHelpers installed.  A helper that isn't recognized is run like pdftotext.
+func resolvePDFHelper() (string, *pdfHelper) {
+	if len(pdf_helper) > 0 {
+		path := pdf_helper
+		if _, err := os.Stat(path); err != nil {
+			path = resolveCommand(pdf_helper)
+		}
+		name := strings.TrimSuffix(filepath.Base(pdf_helper), ".exe")
+		for i := range pdfHelpers {
+			if pdfHelpers[i].name == name {
+				return path, &pdfHelpers[i]
+			}
+		}
+		return path, &pdfHelpers[0]
+	}
+	for i := range pdfHelpers {
+		if path := resolveCommand(pdfHelpers[i].name); len(path) > 0 {
+			conditionalPrint(debug_messages, "Using %s for PDF text\n", path)
+			return path, &pdfHelpers[i]
+		}
+	}
+	return "", nil
+}
+
+// Runs a PDF helper on a file, returning the text.
+func (h *pdfHelper) text(program string, file string) ([]byte, error) {
+	input := file
+	if h.pageFiles { // Work on a link or copy in a temporary directory, so nothing is left beside the PDF.
+		dir, err := os.MkdirTemp("", "dirpdf")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		input = filepath.Join(dir, "in.pdf")
+		if abs, err := filepath.Abs(file); err != nil || os.Symlink(abs, input) != nil {
+			data, err := os.ReadFile(file)
+			if err == nil {
+				err = os.WriteFile(input, data, 0600)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	args := make([]string, len(h.args))
+	for i, a := range h.args {
+		args[i] = strings.ReplaceAll(a, "{}", input)
+	}
+	stdout, err := runHelper(program, file, args...)
+	if err != nil || !h.pageFiles {
+		return stdout, err
+	}
+	pages, _ := filepath.Glob(input + ".*.txt")
+	sort.Strings(pages)
+	for _, page := range pages {
+		if data, err := os.ReadFile(page); err == nil {
+			stdout = append(stdout, data...)
+		}
+	}
+	return stdout, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -tx: search file contents for bytes given in hex, with ? for any nibble, rather than text.
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+const maxHexPatternBytes = 256 // Well within the overlap between search chunks
+
+// One byte of the pattern.  Bits in mask must equal value; a ? nibble leaves them out.
+type hexByte struct {
+	value byte
+	mask  byte
+}
+
+var hex_pattern []hexByte // Set by -tx
+
+// Parses hex like "DEADBEEF", "de ad be ef", "4D5A??00" or "CAFEBAB?".  Spaces are ignored.
+func parseHexPattern(s string) ([]hexByte, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) == 0 || len(s)%2 != 0 {
+		return nil, errors.New("hex needs two digits per byte")
+	}
+	pattern := make([]hexByte, 0, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		var b hexByte
+		for _, c := range s[i : i+2] {
+			b.value <<= 4
+			b.mask <<= 4
+			switch {
+			case c == '?':
+			case c >= '0' && c <= '9':
+				b.value |= byte(c - '0')
+				b.mask |= 0xF
+			case c >= 'a' && c <= 'f':
+				b.value |= byte(c - 'a' + 10)
+				b.mask |= 0xF
+			case c >= 'A' && c <= 'F':
+				b.value |= byte(c - 'A' + 10)
+				b.mask |= 0xF
+			default:
+				return nil, errors.New("not a hex digit or ?: " + string(c))
+			}
+		}
+		pattern = append(pattern, b)
+	}
+	if len(pattern) > maxHexPatternBytes {
+		return nil, errors.New("more than 256 bytes")
+	}
+	return pattern, nil
+}
+
+// Where the pattern is found in data, like regexp's FindAllIndex: up to n non-overlapping
+// matches, or all if n < 0.
+func hexMatchIndexes(data []byte, n int) [][]int {
+	var found [][]int
+	first := hex_pattern[0]
+	for i := 0; i+len(hex_pattern) <= len(data) && (n < 0 || len(found) < n); i++ {
+		if first.mask == 0xFF { // Skip ahead quickly to the first byte
+			next := bytes.IndexByte(data[i:], first.value)
+			if next < 0 {
+				break
+			}
+			i += next
+			if i+len(hex_pattern) > len(data) {
+				break
+			}
+		}
+		matched := true
+		for j, b := range hex_pattern {
+			if data[i+j]&b.mask != b.value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			found = append(found, []int{i, i + len(hex_pattern)})
+			i += len(hex_pattern) - 1
+		}
+	}
+	return found
+}
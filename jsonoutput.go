@@ -0,0 +1,277 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -format=json: the listing as JSON, for other tools.  With a content search, each match is a
+// record with its line and byte offset, so tools can go straight to it.  With -t, -group= or
+// -histogram=, the totals instead, for dashboards.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	maxMatchRecords     = 1000     // Per file
+	maxMatchRecordBytes = 64 << 20 // Of each file, searched for match records
+	maxMatchContext     = 200      // Bytes of the line around a match
+)
+
+var (
+	output_json bool // -format=json
+	all_matches bool // -all-matches: a record for every match, even on a line seen before
+)
+
+type jsonFile struct {
+	Path      string    `json:"path"` // Directory, or the archive for archive members
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Modified  time.Time `json:"modified"`
+	Mode      string    `json:"mode"`
+	IsDir     bool      `json:"isdir,omitempty"`
+	InArchive bool      `json:"inarchive,omitempty"`
+	Container string    `json:"container,omitempty"` // Archive members: the archive
+	Member    string    `json:"member,omitempty"`    // Archive members: the path within it
+	Link      string    `json:"link,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+	Group     string    `json:"group,omitempty"`
+	Matches   int       `json:"matches,omitempty"` // Content search matches; 1 unless all are counted
+}
+
+type matchRecord struct {
+	File    string `json:"file"`              // Full path; archive members are under the archive's path
+	Line    int    `json:"line"`              // From 1
+	Offset  int    `json:"offset"`            // Bytes from the start of the file
+	Text    string `json:"text"`              // What matched; hex for -tx
+	Context string `json:"context"`           // The line it's on, shortened if long
+	Repeats int    `json:"repeats,omitempty"` // Later matches in the file with the same text and context, folded into this
+}
+
+type jsonUnreadable struct {
+	Directories      []string `json:"directories"`
+	Files            int      `json:"files"` // Whose details couldn't be read
+	PermissionDenied int      `json:"permissiondenied"`
+}
+
+type jsonListing struct {
+	Schema  int           `json:"schema"`  // outputSchema
+	Version string        `json:"version"` // Of dir
+	Files   []jsonFile    `json:"files"`
+	Matches []matchRecord `json:"matches,omitempty"`
+	// What couldn't be read, if anything
+	Unreadable *jsonUnreadable `json:"unreadable,omitempty"`
+}
+
+// Counts and bytes, for the JSON summary.  Directories are counted where they are, and add no bytes.
+type jsonTotal struct {
+	Files       int   `json:"files"`
+	Directories int   `json:"directories"`
+	Bytes       int64 `json:"bytes"`
+}
+
+type jsonSummary struct {
+	Schema      int                   `json:"schema"`
+	Version     string                `json:"version"`
+	Root        string                `json:"root"`
+	Generated   time.Time             `json:"generated"`
+	Totals      jsonTotal             `json:"totals"`
+	Extensions  map[string]*jsonTotal `json:"extensions"` // Upper case, as the ext group
+	Types       map[string]*jsonTotal `json:"types"`
+	Languages   map[string]*jsonTotal `json:"languages"`         // Code only
+	Directories map[string]*jsonTotal `json:"directories"`       // What is directly in each, not below it
+	Groups      map[string]*jsonTotal `json:"groups,omitempty"`  // -group= sections, by label
+	Periods     map[string]*jsonTotal `json:"periods,omitempty"` // -histogram= periods, by label
+	Unreadable  *jsonUnreadable       `json:"unreadable,omitempty"`
+}
+
+// True if JSON should be the totals rather than every file.
+func jsonSummaryWanted() bool {
+	return totals_only || len(group_by) > 0 || len(histogram_by) > 0
+}
+
+func (t *jsonTotal) add(f *fileitem) {
+	if f.IsDir {
+		t.Directories++
+	} else {
+		t.Files++
+		t.Bytes += f.Size
+	}
+}
+
+// Adds f to the total for key, starting one if need be.
+func addToTotal(totals map[string]*jsonTotal, key string, f *fileitem) {
+	if totals[key] == nil {
+		totals[key] = &jsonTotal{}
+	}
+	totals[key].add(f)
+}
+
+func printJSONSummary(files []fileitem) {
+	summary := jsonSummary{Schema: outputSchema, Version: versionDate, Root: displayPath(start_directory), Generated: time.Now(),
+		Extensions: map[string]*jsonTotal{}, Types: map[string]*jsonTotal{}, Languages: map[string]*jsonTotal{}, Directories: map[string]*jsonTotal{}}
+	if len(group_by) > 0 {
+		summary.Groups = map[string]*jsonTotal{}
+	}
+	if len(histogram_by) > 0 {
+		summary.Periods = map[string]*jsonTotal{}
+	}
+	for i := range files {
+		f := &files[i]
+		summary.Totals.add(f)
+		addToTotal(summary.Directories, displayPath(f.Path), f)
+		if !f.IsDir {
+			addToTotal(summary.Extensions, ternaryString(len(f.Extension()) > 0, f.Extension(), "(no extension)"), f)
+			addToTotal(summary.Types, f.FileType().String(), f)
+			if language := f.Language(); len(language) > 0 {
+				addToTotal(summary.Languages, language, f)
+			}
+		}
+		if summary.Groups != nil {
+			label, _ := groupOf(f)
+			addToTotal(summary.Groups, label, f)
+		}
+		if summary.Periods != nil && !f.IsDir {
+			label, _ := datePeriod(f.Modified, histogram_by)
+			addToTotal(summary.Periods, label, f)
+		}
+	}
+	walkStats.Lock()
+	if len(walkStats.directories) > 0 || walkStats.files > 0 {
+		summary.Unreadable = &jsonUnreadable{walkStats.directories, walkStats.files, walkStats.permissionDenied}
+	}
+	walkStats.Unlock()
+	data, err := json.MarshalIndent(summary, "", " ")
+	if err != nil {
+		conditionalPrint(show_errors, "Could not write JSON: %s\n", err.Error())
+		return
+	}
+	fmt.Fprintln(output, string(data))
+}
+
+func printJSON(files []fileitem) {
+	listing := jsonListing{Schema: outputSchema, Version: versionDate, Files: []jsonFile{}}
+	for _, f := range files {
+		listing.Files = append(listing.Files, jsonFile{displayPath(f.Path), f.Name, f.Size, f.Modified, f.ModeToString(), f.IsDir,
+			f.InArchive, f.Container(), f.MemberPath(), f.LinkDest, f.Owner, f.Group, f.Matches})
+		if text_search_type != SEARCH_NONE && !f.IsDir {
+			listing.Matches = append(listing.Matches, matchRecords(f)...)
+		}
+	}
+	walkStats.Lock()
+	if len(walkStats.directories) > 0 || walkStats.files > 0 {
+		listing.Unreadable = &jsonUnreadable{walkStats.directories, walkStats.files, walkStats.permissionDenied}
+	}
+	walkStats.Unlock()
+	data, err := json.MarshalIndent(listing, "", " ")
+	if err != nil {
+		conditionalPrint(show_errors, "Could not write JSON: %s\n", err.Error())
+		return
+	}
+	fmt.Fprintln(output, string(data))
+}
+
+// Finds where the search matched in a file or archive member.  Files with a content extractor, such
+// as Office files and PDFs, are searched as extracted text, which has no offsets in the file, so
+// give none.
+func matchRecords(f fileitem) []matchRecord {
+	var data []byte
+	var err error
+	name := filepath.Join(f.Path, f.Name)
+	if text_search_type != SEARCH_HEX && extractorFor(f.Extension()) != nil {
+		return nil
+	}
+	if f.InArchive {
+		if f.Size > maxArchiveMemberBytes {
+			return nil
+		}
+		data, err = archiveMemberBytes(f)
+	} else {
+		var file *os.File
+		if file, err = os.Open(name); err == nil {
+			data, err = io.ReadAll(io.LimitReader(file, maxMatchRecordBytes))
+			file.Close()
+		}
+	}
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read %s for matches: %s\n", name, err.Error())
+		return nil
+	}
+	var records []matchRecord
+	seen := make(map[[2]string]int) // Text and context, to the record they were first found in
+	line, counted := 1, 0
+	for from := 0; from <= len(data); {
+		// A batch at a time; folded repeats don't count toward the limit, and there may be millions.
+		found := findMatches(data[from:], maxMatchRecords)
+		for _, m := range found {
+			start, end := from+m[0], from+m[1]
+			line += bytes.Count(data[counted:start], []byte{'\n'})
+			counted = start
+			record := matchRecord{File: displayPath(name), Line: line, Offset: start, Context: matchContext(data, start, end)}
+			if text_search_type == SEARCH_HEX {
+				record.Text = hex.EncodeToString(data[start:end])
+			} else {
+				record.Text = strings.ToValidUTF8(string(data[start:end]), "�")
+			}
+			if !all_matches { // Repetitive files, such as logs, would otherwise bury the rest
+				key := [2]string{record.Text, record.Context}
+				if first, found := seen[key]; found {
+					records[first].Repeats++
+					continue
+				} else if len(records) == maxMatchRecords {
+					continue // Still counting the repeats of those kept
+				}
+				seen[key] = len(records)
+			}
+			records = append(records, record)
+		}
+		if len(found) < maxMatchRecords || len(records) == maxMatchRecords && all_matches || outOfTime() {
+			break
+		}
+		last := found[len(found)-1]
+		from += ternaryInt(last[1] > last[0], last[1], last[0]+1) // Past an empty match
+	}
+	return records
+}
+
+// The line holding a match, trimmed to maxMatchContext bytes around it.
+func matchContext(data []byte, start int, end int) string {
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+	if lineEnd-lineStart > maxMatchContext {
+		margin := (maxMatchContext - (end - start)) / 2
+		if margin < 0 {
+			margin = 0
+		}
+		if start-margin > lineStart {
+			lineStart = start - margin
+		}
+		if end+margin < lineEnd {
+			lineEnd = end + margin
+		}
+	}
+	return strings.ToValidUTF8(strings.TrimRight(string(data[lineStart:lineEnd]), "\r"), "�")
+}
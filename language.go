@@ -0,0 +1,123 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Programming languages, a finer cut of the CODE type: by extension, or for scripts without one,
+// by the interpreter their #! line names.  For the y column, -lang= and -group=lang.
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var languageExtensions = map[string]string{
+	"ahk": "AutoHotkey", "applescript": "AppleScript", "scpt": "AppleScript", "asm": "Assembly", "s": "Assembly",
+	"au3": "AutoIt", "bas": "BASIC", "vb": "Visual Basic", "vbs": "VBScript", "bat": "Batch", "cmd": "Batch",
+	"c": "C", "h": "C", "cpp": "C++", "cxx": "C++", "cc": "C++", "hpp": "C++", "hxx": "C++", "ino": "Arduino",
+	"cs": "C#", "m": "Objective-C", "mm": "Objective-C", "swift": "Swift", "go": "Go", "rs": "Rust",
+	"java": "Java", "kt": "Kotlin", "kts": "Kotlin", "ktm": "Kotlin", "scala": "Scala", "groovy": "Groovy",
+	"gvy": "Groovy", "gradle": "Groovy", "js": "JavaScript", "mjs": "JavaScript", "cjs": "JavaScript",
+	"es": "JavaScript", "jsx": "JavaScript", "ts": "TypeScript", "tsx": "TypeScript", "coffee": "CoffeeScript",
+	"py": "Python", "pyw": "Python", "rb": "Ruby", "rbw": "Ruby", "rake": "Ruby", "ru": "Ruby", "ruby": "Ruby",
+	"rbx": "Ruby", "pl": "Perl", "pm": "Perl", "perl": "Perl", "ph": "Perl", "php": "PHP", "lua": "Lua",
+	"sh": "Shell", "bash": "Shell", "zsh": "Shell", "ksh": "Shell", "fish": "Shell", "ps1": "PowerShell",
+	"psm1": "PowerShell", "r": "R", "jl": "Julia", "dart": "Dart", "ex": "Elixir", "exs": "Elixir", "erl": "Erlang",
+	"hs": "Haskell", "ml": "OCaml", "fs": "F#", "clj": "Clojure", "lisp": "Lisp", "el": "Emacs Lisp",
+	"sql": "SQL", "v": "Verilog", "vhd": "VHDL", "vhdl": "VHDL", "cmake": "CMake", "mak": "Make", "pp": "Puppet",
+	"zig": "Zig", "nim": "Nim", "tcl": "Tcl",
+}
+
+// Interpreters named by #! lines, without any version number.
+var interpreterLanguages = map[string]string{
+	"sh": "Shell", "bash": "Shell", "zsh": "Shell", "ksh": "Shell", "dash": "Shell", "fish": "Shell",
+	"python": "Python", "perl": "Perl", "ruby": "Ruby", "node": "JavaScript", "deno": "TypeScript", "php": "PHP",
+	"lua": "Lua", "tclsh": "Tcl", "Rscript": "R", "pwsh": "PowerShell", "osascript": "AppleScript",
+}
+
+var languages []string // -lang=: only code in these languages, by name or extension
+
+// The file's language, or "" if it isn't code.  Only files with no extension are opened, for a #! line.
+func (f fileitem) Language() string {
+	if f.IsDir {
+		return ""
+	}
+	if ext := strings.ToLower(f.Extension()); len(ext) > 0 {
+		return languageExtensions[ext]
+	}
+	switch strings.ToLower(f.Name) {
+	case "makefile", "gnumakefile":
+		return "Make"
+	case "dockerfile":
+		return "Dockerfile"
+	case "rakefile", "gemfile":
+		return "Ruby"
+	}
+	if f.InArchive || f.Size < 4 {
+		return ""
+	}
+	return interpreterLanguages[shebangInterpreter(filepath.Join(f.Path, f.Name))]
+}
+
+// The program a script's #! line runs, e.g. python for "#!/usr/bin/env python3.11".
+func shebangInterpreter(filename string) string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	line := make([]byte, 128)
+	n, _ := file.Read(line)
+	line = line[:n]
+	if !bytes.HasPrefix(line, []byte("#!")) {
+		return ""
+	}
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	words := strings.Fields(string(line[2:]))
+	if len(words) == 0 {
+		return ""
+	}
+	program := path.Base(words[0])
+	if program == "env" { // The program is the first word that isn't an option to env
+		program = ""
+		for _, w := range words[1:] {
+			if !strings.HasPrefix(w, "-") {
+				program = path.Base(w)
+				break
+			}
+		}
+	}
+	return strings.TrimRight(program, "0123456789.")
+}
+
+// Whether the file is in one of the -lang= languages, named or by one of their extensions.
+func languageMatches(f *fileitem) bool {
+	language := f.Language()
+	if len(language) == 0 {
+		return false
+	}
+	for _, l := range languages {
+		if strings.EqualFold(l, language) || languageExtensions[strings.ToLower(l)] == language {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Following symlinks to the end, for the r column and -linkto.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gobwas/glob"
+)
+
+const maxLinkHops = 40 // As Linux's limit
+
+var linkto_matcher glob.Glob // -linkto
+
+// Follows a chain of symlinks from path to what it finally names.  hops is the number of links
+// followed.  loop is true if the chain comes back on itself or is too long; broken if the end
+// doesn't exist.
+func resolveLinkChain(path string) (final string, hops int, loop bool, broken bool) {
+	visited := map[string]bool{}
+	current := path
+	for {
+		target, err := os.Readlink(current)
+		if err != nil { // Not a link: the end of the chain
+			_, statErr := os.Lstat(current)
+			return current, hops, false, statErr != nil
+		}
+		if visited[current] || hops == maxLinkHops {
+			return current, hops, true, false
+		}
+		visited[current] = true
+		hops++
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(current), target)
+		}
+		current = filepath.Clean(target)
+	}
+}
+
+// The end of a symlink's chain and how many links lead there, for the r column.
+func (f fileitem) LinkChain() string {
+	if len(f.LinkDest) == 0 || f.InArchive {
+		return ""
+	}
+	final, hops, loop, broken := resolveLinkChain(filepath.Join(f.Path, f.Name))
+	switch {
+	case loop:
+		return fmt.Sprintf("LOOP after %d links at %s", hops, final)
+	case broken:
+		return fmt.Sprintf("=> %s (%d, broken)", final, hops)
+	}
+	return fmt.Sprintf("=> %s (%d)", final, hops)
+}
+
+// Whether a symlink points at something matching -linkto: either its own target, made absolute,
+// or the end of its chain.
+func linkTargetMatches(f *fileitem) bool {
+	if len(f.LinkDest) == 0 || f.InArchive {
+		return false
+	}
+	target := f.LinkDest
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(f.Path, target)
+	}
+	if linkto_matcher.Match(filepath.Clean(target)) {
+		return true
+	}
+	final, _, _, _ := resolveLinkChain(filepath.Join(f.Path, f.Name))
+	return linkto_matcher.Match(final)
+}
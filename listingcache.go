@@ -0,0 +1,163 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -cache: keeps what was read from each directory on disk, so running again over the same tree -
+// with another sort, other columns, another filter - needn't read it all again.  A directory is
+// read afresh once its modification time changes, which happens when anything in it is added,
+// removed or renamed, or once the cache is older than the -cache= time.  Files rewritten in
+// place don't change their directory, so may show old sizes and times until then.
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultCacheAge = 10 * time.Minute
+
+type cachedEntry struct {
+	Name     string      `json:"name"`
+	Size     int64       `json:"size"`
+	Modified time.Time   `json:"modified"`
+	Created  time.Time   `json:"created"`
+	Accessed time.Time   `json:"accessed"`
+	Changed  time.Time   `json:"changed"`
+	IsDir    bool        `json:"isdir,omitempty"`
+	Mode     fs.FileMode `json:"mode"`
+	LinkDest string      `json:"link,omitempty"`
+	Links    uint64      `json:"links"`
+	Owner    string      `json:"owner,omitempty"`
+	Group    string      `json:"group,omitempty"`
+}
+
+type cachedDirectory struct {
+	Read     time.Time     `json:"read"`
+	Modified time.Time     `json:"modified"` // The directory's, when read
+	Owners   bool          `json:"owners"`   // Owner and Group were read
+	Entries  []cachedEntry `json:"entries"`
+}
+
+var (
+	use_cache      bool
+	cache_max_age  = defaultCacheAge
+	listingCache   map[string]cachedDirectory // By directory, for this start directory
+	listingChanged bool
+)
+
+// The cache file for the start directory, under the user cache directory, or beside the
+// executable if portable.
+func listingCachePath() string {
+	dir := filesDir(os.UserCacheDir)
+	if len(dir) == 0 {
+		return ""
+	}
+	root, _ := filepath.Abs(start_directory)
+	hash := fnv.New64a()
+	hash.Write([]byte(root))
+	return filepath.Join(dir, fmt.Sprintf("listing-%016x.json", hash.Sum64()))
+}
+
+// True if this run can use the cache.  -fast reads too little to keep, and hard link details
+// aren't kept.
+func cacheUsable() bool {
+	return use_cache && !stat_free && !fileIDsNeeded()
+}
+
+// Reads the directory from the cache if it's still good, or else from disk, caching everything in
+// it.  Returns the entries keep accepts, as readDirectory does.
+func readDirectoryCached(target string, keep func(*fileitem) bool) ([]fileitem, error) {
+	if listingCache == nil {
+		loadListingCache()
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return readDirectory(target, keep)
+	}
+	key, _ := filepath.Abs(target)
+	var all []fileitem
+	cached, found := listingCache[key]
+	if found && cached.Modified.Equal(info.ModTime()) && time.Since(cached.Read) < cache_max_age && (cached.Owners || !owner_needed) {
+		conditionalPrint(debug_messages, "Using the cached listing of %s\n", target)
+		for _, e := range cached.Entries {
+			all = append(all, fileitem{Path: target, Name: e.Name, Size: e.Size, Modified: e.Modified, Created: e.Created,
+				Accessed: e.Accessed, Changed: e.Changed, IsDir: e.IsDir, Mode: e.Mode, LinkDest: e.LinkDest, Links: e.Links, Owner: e.Owner, Group: e.Group})
+		}
+	} else {
+		all, err = readDirectory(target, func(*fileitem) bool { return true })
+		if err != nil || outOfTime() { // Possibly incomplete
+			return filterEntries(all, keep), err
+		}
+		cached = cachedDirectory{Read: time.Now(), Modified: info.ModTime(), Owners: owner_needed}
+		for _, f := range all {
+			cached.Entries = append(cached.Entries, cachedEntry{f.Name, f.Size, f.Modified, f.Created, f.Accessed, f.Changed, f.IsDir, f.Mode,
+				f.LinkDest, f.Links, f.Owner, f.Group})
+		}
+		listingCache[key] = cached
+		listingChanged = true
+	}
+	return filterEntries(all, keep), nil
+}
+
+func filterEntries(files []fileitem, keep func(*fileitem) bool) []fileitem {
+	var kept []fileitem
+	for i := range files {
+		if keep(&files[i]) {
+			kept = append(kept, files[i])
+		}
+	}
+	return kept
+}
+
+func loadListingCache() {
+	listingCache = map[string]cachedDirectory{}
+	data, err := os.ReadFile(listingCachePath())
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &listingCache); err != nil {
+		conditionalPrint(show_errors, "Ignoring the listing cache: %s\n", err.Error())
+		listingCache = map[string]cachedDirectory{}
+	}
+}
+
+// Writes the cache back, if anything was read afresh, dropping directories past their age.
+func saveListingCache() {
+	if !listingChanged {
+		return
+	}
+	for dir, cached := range listingCache {
+		if time.Since(cached.Read) >= cache_max_age {
+			delete(listingCache, dir)
+		}
+	}
+	path := listingCachePath()
+	data, err := json.Marshal(listingCache)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0600)
+	}
+	if err != nil {
+		conditionalPrint(show_errors, "Could not write the listing cache: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Which file system each file is on (the f column), and -mounts, listing mounted volumes.
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type mount struct {
+	device string
+	point  string // Where it's mounted; a drive root on Windows
+	fstype string // e.g. apfs, ext4, ntfs, nfs, smb
+	total  uint64 // Bytes
+	free   uint64 // Bytes available to us
+}
+
+var (
+	list_mounts bool                  // -mounts
+	fsTypes     = map[string]string{} // By directory, as files in one directory are (almost) always on one file system
+)
+
+// The file system type, e.g. ext4 or nfs.  Blank for archive members.
+func (f fileitem) FileSystem() string {
+	if f.InArchive {
+		return ""
+	}
+	dir := f.Path
+	if f.IsDir { // A directory may be a mount point itself
+		dir = filepath.Join(f.Path, f.Name)
+	}
+	if fstype, found := fsTypes[dir]; found {
+		return fstype
+	}
+	fsTypes[dir] = fsType(dir)
+	return fsTypes[dir]
+}
+
+// Prints the mounted volumes, with their usage.
+func printMounts() {
+	mounts := mountedVolumes()
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].point < mounts[j].point })
+	if directory_header {
+		fmt.Fprintf(output, "\n   %-30s %-8s %14s %14s %14s  %4s  %s\n\n", "Mounted on", "Type", "Size", "Used", "Free", "Use%", "Device")
+	}
+	for _, m := range mounts {
+		if m.total == 0 && !listhidden { // proc, sysfs and the like
+			continue
+		}
+		used := m.total - m.free
+		percent := ""
+		if m.total > 0 {
+			percent = fmt.Sprintf("%3d%%", used*100/m.total)
+		}
+		if bare {
+			fmt.Fprintln(output, m.point)
+			continue
+		}
+		fmt.Fprintf(output, "   %-30s %-8s %14s %14s %14s  %4s  %s\n", m.point, m.fstype, strings.TrimSpace(FileSizeToString(int64(m.total))),
+			strings.TrimSpace(FileSizeToString(int64(used))), strings.TrimSpace(FileSizeToString(int64(m.free))), percent, m.device)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build darwin || freebsd
+
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// File system types and mounts from statfs and getfsstat.
+
+import (
+	"syscall"
+)
+
+const mntNoWait = 2 // MNT_NOWAIT: don't wait on unresponsive network mounts for their statistics
+
+func int8String(chars []int8) string {
+	b := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
+
+func fsType(path string) string {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return ""
+	}
+	return int8String(stat.Fstypename[:])
+}
+
+func mountedVolumes() []mount {
+	n, err := syscall.Getfsstat(nil, mntNoWait)
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read mounts: %s\n", err.Error())
+		return nil
+	}
+	stats := make([]syscall.Statfs_t, n)
+	n, err = syscall.Getfsstat(stats, mntNoWait)
+	if err != nil {
+		return nil
+	}
+	var mounts []mount
+	for _, stat := range stats[:n] {
+		mounts = append(mounts, mount{int8String(stat.Mntfromname[:]), int8String(stat.Mntonname[:]), int8String(stat.Fstypename[:]),
+			stat.Blocks * uint64(stat.Bsize), uint64(stat.Bavail) * uint64(stat.Bsize)})
+	}
+	return mounts
+}
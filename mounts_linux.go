@@ -0,0 +1,100 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Mounts from /proc/self/mounts, and usage from statfs.
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var procMounts []mount // Read once
+
+// Mount points escape spaces and the like as \040.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				out.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+func readProcMounts() []mount {
+	if procMounts != nil {
+		return procMounts
+	}
+	procMounts = []mount{}
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read mounts: %s\n", err.Error())
+		return procMounts
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 {
+			procMounts = append(procMounts, mount{device: unescapeMount(fields[0]), point: unescapeMount(fields[1]), fstype: fields[2]})
+		}
+	}
+	return procMounts
+}
+
+// The type of the last-mounted file system whose mount point holds the path.
+func fsType(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	best, fstype := -1, ""
+	for _, m := range readProcMounts() {
+		if (path == m.point || m.point == "/" || strings.HasPrefix(path, m.point+"/")) && len(m.point) >= best {
+			best, fstype = len(m.point), m.fstype
+		}
+	}
+	return fstype
+}
+
+func mountedVolumes() []mount {
+	var mounts []mount
+	for _, m := range readProcMounts() {
+		var stat syscall.Statfs_t
+		if syscall.Statfs(m.point, &stat) == nil {
+			m.total = stat.Blocks * uint64(stat.Bsize)
+			m.free = stat.Bavail * uint64(stat.Bsize)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// File system types and drives, from the volume APIs.
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procGetVolumePathName      = kernel32.NewProc("GetVolumePathNameW")
+	procGetVolumeInformation   = kernel32.NewProc("GetVolumeInformationW")
+	procGetDriveType           = kernel32.NewProc("GetDriveTypeW")
+	procGetDiskFreeSpaceEx     = kernel32.NewProc("GetDiskFreeSpaceExW")
+	procGetLogicalDriveStrings = kernel32.NewProc("GetLogicalDriveStringsW")
+)
+
+const DRIVE_REMOTE = 4
+
+// The file system of a volume root, e.g. C:\.  Network drives are shown as smb, whatever the
+// server's own file system is.
+func volumeType(root string) string {
+	rootp, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return ""
+	}
+	if driveType, _, _ := procGetDriveType.Call(uintptr(unsafe.Pointer(rootp))); driveType == DRIVE_REMOTE {
+		return "smb"
+	}
+	var name [64]uint16
+	ret, _, _ := procGetVolumeInformation.Call(uintptr(unsafe.Pointer(rootp)), 0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&name[0])), uintptr(len(name)))
+	if ret == 0 {
+		return ""
+	}
+	return strings.ToLower(syscall.UTF16ToString(name[:]))
+}
+
+func fsType(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	pathp, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return ""
+	}
+	var root [syscall.MAX_PATH + 1]uint16
+	ret, _, _ := procGetVolumePathName.Call(uintptr(unsafe.Pointer(pathp)), uintptr(unsafe.Pointer(&root[0])), uintptr(len(root)))
+	if ret == 0 {
+		return ""
+	}
+	return volumeType(syscall.UTF16ToString(root[:]))
+}
+
+func mountedVolumes() []mount {
+	var buffer [1024]uint16
+	n, _, _ := procGetLogicalDriveStrings.Call(uintptr(len(buffer)), uintptr(unsafe.Pointer(&buffer[0])))
+	var mounts []mount
+	for _, root := range strings.Split(syscall.UTF16ToString(buffer[:n]), "\x00") {
+		if len(root) == 0 {
+			continue
+		}
+		m := mount{device: root, point: root, fstype: volumeType(root)}
+		if rootp, err := syscall.UTF16PtrFromString(root); err == nil {
+			procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(rootp)), uintptr(unsafe.Pointer(&m.free)), uintptr(unsafe.Pointer(&m.total)), 0)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The q column: how many sheets, slides or pages an Office document has, for triaging a pile of
+// them.  Office Open XML files are zips with a part for each sheet or slide, so counting them
+// needs only the zip's directory.  Word doesn't keep a part per page, but records the count when
+// saving.
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Each format's parts, by the start of their names, and what they are.
+var officeParts = map[string]struct{ prefix, unit string }{
+	"XLSX": {"xl/worksheets/sheet", "sheet"},
+	"XLSM": {"xl/worksheets/sheet", "sheet"},
+	"PPTX": {"ppt/slides/slide", "slide"},
+	"PPTM": {"ppt/slides/slide", "slide"},
+	"VSDX": {"visio/pages/page", "page"},
+}
+
+var wordPages = regexp.MustCompile(`<Pages>(\d+)</Pages>`) // In docProps/app.xml
+
+// e.g. "12 slides".  Blank for anything else, or a document that can't be read.
+func (f fileitem) OfficeInfo() string {
+	ext := f.Extension()
+	if f.IsDir || f.InArchive || (ext != "DOCX" && ext != "DOCM" && officeParts[ext].unit == "") {
+		return ""
+	}
+	zipReader, err := zip.OpenReader(filepath.Join(f.Path, f.Name))
+	if err != nil {
+		conditionalPrint(show_errors, "Could not open %s: %s\n", f.Name, err.Error())
+		return ""
+	}
+	defer zipReader.Close()
+	count, unit := 0, "page"
+	if parts, found := officeParts[ext]; found {
+		unit = parts.unit
+		for _, part := range zipReader.File {
+			if strings.HasPrefix(part.Name, parts.prefix) && strings.HasSuffix(part.Name, ".xml") && !strings.Contains(part.Name[len(parts.prefix):], "/") {
+				count++
+			}
+		}
+	} else if count = savedPageCount(zipReader); count < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d %s%s", count, unit, ternaryString(count == 1, "", "s"))
+}
+
+// The page count Word saved in the document properties, or -1 if there isn't one.
+func savedPageCount(zipReader *zip.ReadCloser) int {
+	for _, part := range zipReader.File {
+		if part.Name != "docProps/app.xml" || part.UncompressedSize64 > maxArchiveMemberBytes {
+			continue
+		}
+		reader, err := part.Open()
+		if err != nil {
+			return -1
+		}
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return -1
+		}
+		if m := wordPages.FindSubmatch(data); m != nil {
+			if n, err := strconv.Atoi(string(m[1])); err == nil {
+				return n
+			}
+		}
+	}
+	return -1
+}
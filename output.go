@@ -0,0 +1,70 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Standard output goes through one buffer, rather than a write for every line, which is most of
+// the time taken listing a huge directory to a terminal.  It's flushed at the end, and wherever
+// the order matters - before a prompt, or a command writing its own output.  -unbuffered writes
+// as it goes, for following a slow search or watch as it happens.
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+const outputBufferSize = 64 << 10
+
+var (
+	unbuffered   bool                     // -unbuffered
+	stdoutBuffer *bufio.Writer            // nil when unbuffered
+	output       io.Writer     = stdout{} // Where everything printed goes
+	outputLock   sync.Mutex               // Held writing to output from the search workers
+)
+
+// os.Stdout as it is at the time, as the pager replaces it.
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// Buffers from here on, unless -unbuffered.  Until then, output is written as it comes, so messages
+// about the flags get out before an early exit.
+func startOutput() {
+	if !unbuffered {
+		stdoutBuffer = bufio.NewWriterSize(stdout{}, outputBufferSize)
+		setOutput(stdoutBuffer)
+	}
+}
+
+// Sends what's printed to w from here on, returning where it went before.
+func setOutput(w io.Writer) io.Writer {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	was := output
+	output = w
+	return was
+}
+
+// Writes out anything buffered.
+func flushOutput() {
+	if stdoutBuffer != nil {
+		stdoutBuffer.Flush()
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -pause (DOS /p): stop after each screenful of output.
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	paginate   bool
+	realStdout *os.File      // The terminal, while os.Stdout is the pager's pipe
+	pagerDone  chan struct{} // Closed when the pager has printed everything
+)
+
+// Screen height, from $LINES if the shell exports it.
+func screenLines() int {
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 2 {
+		return n
+	}
+	return 24
+}
+
+// Routes output through the pager, if -pause and writing to a terminal.
+func startPager() {
+	if !paginate {
+		return
+	}
+	if fi, err := os.Stdout.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		conditionalPrint(show_errors, "Could not start pager: %s\n", err.Error())
+		return
+	}
+	realStdout, os.Stdout = os.Stdout, writer
+	pagerDone = make(chan struct{})
+	go func() {
+		defer close(pagerDone)
+		pageLines := screenLines() - 1
+		keys := bufio.NewReader(os.Stdin)
+		lines := bufio.NewReader(reader)
+		for n := 1; ; n++ {
+			line, err := lines.ReadString('\n')
+			fmt.Fprint(realStdout, line)
+			if err != nil {
+				return
+			}
+			if n%pageLines == 0 {
+				fmt.Fprint(realStdout, "Press Enter to continue, q to quit . . .")
+				answer, _ := keys.ReadString('\n')
+				if strings.HasPrefix(strings.ToLower(answer), "q") {
+					os.Exit(0)
+				}
+			}
+		}
+	}()
+}
+
+// Waits for the pager to print the rest.
+func stopPager() {
+	if pagerDone == nil {
+		return
+	}
+	os.Stdout.Close()
+	os.Stdout = realStdout
+	<-pagerDone
+}
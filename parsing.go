@@ -20,19 +20,25 @@ package main
 import (
 	_ "embed"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/gobwas/glob"
 )
 
-// Format-Print only if cond == true
+// Format-Print only if cond == true.  Safe from the search workers, which print their errors
+// while the walk waits on them.
 func conditionalPrint(cond bool, format string, a ...any) {
 	if cond {
-		fmt.Printf(format, a...)
+		outputLock.Lock()
+		defer outputLock.Unlock()
+		fmt.Fprintf(output, format, a...)
 	}
 }
 
@@ -43,11 +49,16 @@ func conditionalPrint(cond bool, format string, a ...any) {
 //	  Passed value has both.  i.e. the beginning is a directory to start in,
 //  	 with a wildcard or filename at the end.  Has a slash + content.
 
+//	  Several masks, comma-separated or as separate parameters, list what matches any of them.
+//	  There's only one start directory.
+
 func parseFileName(param string) {
 	fileMask := param
-	conditionalPrint((show_errors || debug_messages) && (len(start_directory) > 0 || filenameParsed),
-		"  *** WARNING: Multiple filename parameters found.  Had %s %s, now %s.\nShould you quote to avoid globbing?\n",
-		start_directory, file_mask, param)
+	previous := start_directory
+	defer func() {
+		conditionalPrint((show_errors || debug_messages) && len(previous) > 0 && start_directory != previous,
+			"  *** WARNING: Multiple start directories found.  Had %s, now %s.\n", previous, start_directory)
+	}()
 	conditionalPrint(debug_messages, "Parsing file name %s\n", param)
 	if strings.HasPrefix(param, "~") {
 		home, _ := os.UserHomeDir()
@@ -73,8 +84,8 @@ func parseFileName(param string) {
 				start_directory = dirPath
 				fileMask = param[strings.LastIndex(param, "/")+1:]
 			} else {
-				extension := "," + dirPath[strings.LastIndex(dirPath, ".")+1:] + ","
-				if strings.Contains(Extensions[ARCHIVE], extension) {
+				extension := strings.ToLower(dirPath[strings.LastIndex(dirPath, ".")+1:])
+				if extensionTypes[extension] == ARCHIVE {
 					// Flag this as the source file to be read.
 					pathIsArchive = true
 					start_directory = dirPath
@@ -89,35 +100,87 @@ func parseFileName(param string) {
 		start_directory = param
 		return
 	}
-	// We have a mask.  Build the globber
-	file_mask = fileMask
-	haveGlobber = true //	 We don't yet have it... we have to process all the parameters to see if case-sensitive first.
-	filenameParsed = true
-	conditionalPrint(debug_messages, "Parameter %s parsed to directory %s, file mask %s.\n", param, start_directory, file_mask)
+	// We have a mask, or several.  Build the globbers
+	file_masks = append(file_masks, fileMask)
+	haveGlobber = true //	 We don't yet have them... we have to process all the parameters to see if case-sensitive first.
+	conditionalPrint(debug_messages, "Parameter %s parsed to directory %s, file masks %v.\n", param, start_directory, file_masks)
+}
+
+// "*.go,*.md" as two masks, once the start directory is known.  Commas in a {} group, as
+// *.{go,md}, or escaped, are the glob's; a file that has the comma in its name is left as it is.
+func splitMasks(mask string) []string {
+	if _, err := os.Stat(filepath.Join(start_directory, mask)); err == nil {
+		return []string{mask}
+	}
+	var masks []string
+	depth, from := 0, 0
+	for i := 0; i <= len(mask); i++ {
+		switch {
+		case i == len(mask) || (mask[i] == ',' && depth == 0):
+			if i > from {
+				masks = append(masks, mask[from:i])
+			}
+			from = i + 1
+		case mask[i] == '\\':
+			i++
+		case mask[i] == '{':
+			depth++
+		case mask[i] == '}' && depth > 0:
+			depth--
+		}
+	}
+	return masks
 }
 
 func parseDateRange(v string) (time.Time, time.Time) {
-	var err error
-	dateRange := strings.Split(v, ":")
-	if len(dateRange) == 0 {
+	// Times have colons too, so the separator is the colon with a valid date or nothing either side.
+	from, to, ok := v, "", true
+	for i := 0; i <= len(v); i++ {
+		if i < len(v) && v[i] != ':' {
+			continue
+		}
+		from, to = v[:i], ""
+		if i < len(v) {
+			to = v[i+1:]
+		}
+		_, errFrom := parseDateTime(from)
+		_, errTo := parseDateTime(to)
+		if ok = (errFrom == nil || len(from) == 0) && (errTo == nil || len(to) == 0); ok {
+			break
+		}
+	}
+	if !ok {
 		conditionalPrint(show_errors, "Invalid date range: %s\n", v)
 		return mindate, maxdate
 	}
-	mindate, err = time.Parse("2006-01-02", dateRange[0])
-	if err != nil {
-		conditionalPrint(show_errors, "Invalid date range: %s - %s\n", v, err.Error())
+	if len(from) > 0 {
+		mindate, _ = parseDateTime(from)
 	}
-	if (len(dateRange) > 1) && (len(dateRange[1]) > 1) {
-		maxdate, err = time.Parse("2006-01-02", dateRange[1])
-		if err != nil {
-			conditionalPrint(show_errors, "Invalid date range: %s - %s\n", v, err.Error())
+	if len(to) > 0 {
+		maxdate, _ = parseDateTime(to)
+		if len(to) == len("2006-01-02") { // The whole day
+			maxdate = maxdate.Add((time.Hour * 24) - time.Duration(maxdate.Hour()))
 		}
-		maxdate = maxdate.Add((time.Hour * 24) - time.Duration(maxdate.Hour()))
 	}
 	return mindate, maxdate
 }
 
-func parseSizeRange(v string) {
+// Parses a date, or a local date and time with optional fractions of a second, e.g. 2024-02-01T10:15:30.250
+func parseDateTime(v string) (time.Time, error) {
+	if len(v) == len("2006-01-02") {
+		return time.Parse("2006-01-02", v)
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", v, time.Local)
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02 15:04:05.999999999", v, time.Local)
+	}
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02T15:04", v, time.Local)
+	}
+	return t, err
+}
+
+func parseSizeRange(v string, minsize *int64, maxsize *int64) {
 	var err error
 	sizeRange := strings.Split(v, ":")
 	if len(sizeRange) == 0 {
@@ -125,21 +188,137 @@ func parseSizeRange(v string) {
 		return
 	}
 	if len(sizeRange[0]) > 0 {
-		minsize, err = strconv.ParseInt(sizeRange[0], 10, 64)
+		*minsize, err = strconv.ParseInt(sizeRange[0], 10, 64)
 		if err != nil {
 			conditionalPrint(show_errors, "Invalid size range: %s - %s\n", v, err.Error())
 		}
 	}
 	if len(sizeRange) > 1 && len(sizeRange[1]) > 0 {
-		maxsize, err = strconv.ParseInt(sizeRange[1], 10, 64)
+		*maxsize, err = strconv.ParseInt(sizeRange[1], 10, 64)
 		if err != nil {
 			conditionalPrint(show_errors, "Invalid size range: %s - %s\n", v, err.Error())
 		}
 	}
 }
 
+// Splits -tt=code,document,md into file types and extensions.
+func parseSearchTypes(v string) {
+	for _, t := range strings.Split(v, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), ".")
+		if ft, found := fileTypeNames[strings.ToLower(t)]; found {
+			search_types = append(search_types, ft)
+		} else if len(t) > 0 {
+			search_exts = append(search_exts, strings.ToUpper(t))
+		}
+	}
+}
+
+// Compiles text_regex once all the flags are known, as -tw and -ts change it.
+func buildTextSearch() {
+	if len(patterns_file) > 0 {
+		if err := loadSearchPatterns(patterns_file, text_search_type == SEARCH_NOCASE); err != nil {
+			conditionalPrint(true, "Could not load patterns from %s: %s\n", patterns_file, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if text_search_type != SEARCH_CASE && text_search_type != SEARCH_NOCASE && text_search_type != SEARCH_REGEX {
+		return
+	}
+	if smart_case && hasUpperCase(search_text) {
+		text_search_type = SEARCH_CASE
+	}
+	expr := wholeWord(search_text)
+	if text_search_type == SEARCH_NOCASE {
+		expr = "(?i)" + expr
+	}
+	text_regex = regexp.MustCompile(multilineFlags() + expr)
+}
+
+// For -tm: . matches newlines, and ^ and $ match at the start and end of each line.
+func multilineFlags() string {
+	return ternaryString(multiline, "(?sm)", "")
+}
+
+// Wraps a pattern in word boundaries for -tw.
+func wholeWord(expr string) string {
+	if whole_word {
+		return `\b(?:` + expr + `)\b`
+	}
+	return expr
+}
+
+// Whether a pattern has an upper case letter, ignoring escapes like \S, for -ts.
+func hasUpperCase(expr string) bool {
+	escaped := false
+	for _, r := range expr {
+		if !escaped && unicode.IsUpper(r) {
+			return true
+		}
+		escaped = !escaped && r == '\\'
+	}
+	return false
+}
+
+// Parses a number for a flag like -archive-members=.  Returns -1 if it is not a non-negative number.
+func parseNonNegative(flag string, value string) int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		conditionalPrint(show_errors, "Invalid %s: %s\n", flag, value)
+		return -1
+	}
+	return n
+}
+
+// Subcommands and the flags they stand for.  A %s takes the next non-flag argument.
+var subcommands = map[string][]string{
+	"list":   {},
+	"search": {"-r", "-ti=%s"},
+	"stats":  {"-r", "-t"},
+	"tree":   {"-r", "-d+"},
+	"diff":   {"-r", "-since=%s"},
+	"index":  {"-r", "-t", "-snapshot=%s"},
+}
+
+// If the first argument is a subcommand, replace it with its flags.  Plain "dir <path>"
+// still works; an existing file or directory with a subcommand's name is treated as a path.
+func expandSubcommand(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	flags, found := subcommands[args[0]]
+	if !found {
+		return args
+	}
+	if _, err := os.Stat(args[0]); err == nil {
+		conditionalPrint(debug_messages, "%s exists, so treating it as a path, not a subcommand.\n", args[0])
+		return args
+	}
+	rest := args[1:]
+	var expanded []string
+	for _, f := range flags {
+		if strings.Contains(f, "%s") {
+			i := 0
+			for i < len(rest) && strings.HasPrefix(rest[i], "-") {
+				i++
+			}
+			if i == len(rest) {
+				fmt.Fprintf(output, "dir %s needs an argument.  See dir -help.\n", args[0])
+				os.Exit(1)
+			}
+			f = fmt.Sprintf(f, rest[i])
+			rest = append(rest[:i:i], rest[i+1:]...)
+		}
+		expanded = append(expanded, f)
+	}
+	conditionalPrint(debug_messages, "Subcommand %s expanded to %v\n", args[0], expanded)
+	return append(expanded, rest...)
+}
+
 func parseCmdLine() {
-	var args = os.Args[1:] // 0 is program name
+	portable = portableRequested(os.Args[1:])
+	loadConfig(defaultConfigPath())
+	var args = expandDOSSwitches(expandSubcommand(normalizeFlags(expandAliases(os.Args[1:], 0)))) // 0 is program name
 	// args is all strings that are space-separated.
 	// The filename is the only thing that doesn't start with - or /
 	for i, s := range args {
@@ -166,13 +345,13 @@ func parseCmdLine() {
 			p := s[1:]
 			values := ""
 			if strings.Contains(p, "=") {
-				pieces := strings.Split(p, "=")
+				pieces := strings.SplitN(p, "=", 2)
 				p = pieces[0]
 				values = pieces[1]
 			}
 			switch p {
 			case "?", "h", "help", "-help", "-h":
-				fmt.Println(helptext)
+				fmt.Fprintln(output, helptext)
 				os.Exit(0)
 			case "on":
 				sortby = sortorder{SORT_NAME, true}
@@ -190,6 +369,10 @@ func parseCmdLine() {
 				sortby = sortorder{SORT_ACCESSED, true}
 			case "o-a":
 				sortby = sortorder{SORT_ACCESSED, false}
+			case "oi": // Inode change time
+				sortby = sortorder{SORT_CHANGED, true}
+			case "o-i":
+				sortby = sortorder{SORT_CHANGED, false}
 			case "ox":
 				sortby = sortorder{SORT_EXT, true}
 			case "o-x":
@@ -202,10 +385,128 @@ func parseCmdLine() {
 				sortby = sortorder{SORT_SIZE, true}
 			case "o-s":
 				sortby = sortorder{SORT_SIZE, false}
+			case "oz":
+				sortby = sortorder{SORT_COMPRESSED, true}
+			case "o-z":
+				sortby = sortorder{SORT_COMPRESSED, false}
+			case "ow":
+				sortby = sortorder{SORT_OWNER, true}
+			case "o-w":
+				sortby = sortorder{SORT_OWNER, false}
+			case "ou": // Directories by the size of all they hold
+				sortby = sortorder{SORT_TREE, true}
+			case "o-u":
+				sortby = sortorder{SORT_TREE, false}
+			case "or": // Most content-search matches first
+				sortby = sortorder{SORT_RELEVANCE, true}
+			case "o-r":
+				sortby = sortorder{SORT_RELEVANCE, false}
+			case "tie": // Tiebreaker for equal sort keys
+				switch values {
+				case "n", "name":
+					sort_tiebreak = SORT_NAME
+				case "p", "path":
+					sort_tiebreak = SORT_PATH
+				case "x", "ext":
+					sort_tiebreak = SORT_EXT
+				case "o", "none":
+					sort_tiebreak = SORT_NATURAL
+				default:
+					conditionalPrint(show_errors, "Unknown tiebreaker %s; use n, p, x or o.\n", values)
+				}
+			case "group":
+				group_by = parseGroupBy(values)
+			case "helper-timeout": // Per-file limit for pdftotext and the like
+				if d, err := time.ParseDuration(values); err == nil {
+					helper_timeout = d
+				} else {
+					conditionalPrint(show_errors, "Invalid helper-timeout: %s - %s\n", values, err.Error())
+				}
+			case "helper-max-output":
+				if n := parseNonNegative(p, values); n > 0 {
+					helper_max_output = n
+				}
+			case "mounts": // List mounted volumes instead
+				list_mounts = true
+			case "pdf-helper": // pdftotext, mutool or pdfium_test, by name or path
+				pdf_helper = values
+			case "hardlinks": // Report files sharing data
+				hardlink_report = true
+			case "count-links-once":
+				count_links_once = true
+			case "histogram":
+				histogram_by = parseHistogram(values)
+			case "week-start":
+				parseWeekStart(values)
+			case "fiscal":
+				parseFiscalStart(values)
+			case "archive-ratio":
+				if n := parseNonNegative(p, values); n >= 0 {
+					archive_max_ratio = n
+				}
+			case "archive-members":
+				if n := parseNonNegative(p, values); n >= 0 {
+					archive_max_members = int(n)
+				}
+			case "archive-depth":
+				if n := parseNonNegative(p, values); n >= 0 {
+					archive_max_depth = int(n)
+				}
+			case "archive-time":
+				if d, err := time.ParseDuration(values); err == nil {
+					archive_time_budget = d
+				} else {
+					conditionalPrint(show_errors, "Invalid archive-time: %s - %s\n", values, err.Error())
+				}
 			case "ah-":
 				listhidden = false
-			case "cs":
-				case_sensitive = true
+			case "ah+": // Only hidden files
+				listhidden = true
+				only_hidden = true
+			case "ar+": // Only read-only files
+				readonly_filter = 1
+			case "ar-": // Only writable files
+				readonly_filter = -1
+			case "perm": // Mode bits, as find -perm
+				filter, err := parsePermFilter(values)
+				if err != nil {
+					conditionalPrint(true, "Invalid perm %s: %s\n", values, err.Error())
+					os.Exit(1)
+				}
+				perm_filters = append(perm_filters, filter)
+			case "readable", "readable+": // What the current user can actually do, ACLs and all
+				access_required |= ACCESS_READ
+			case "readable-":
+				access_refused |= ACCESS_READ
+			case "writable", "writable+":
+				access_required |= ACCESS_WRITE
+			case "writable-":
+				access_refused |= ACCESS_WRITE
+			case "executable", "executable+":
+				access_required |= ACCESS_EXECUTE
+			case "executable-":
+				access_refused |= ACCESS_EXECUTE
+			case "za": // Archives themselves, not their members
+				archives_only = true
+			case "zhas": // Archives with a member matching
+				member_pattern = values
+				archives_only = true
+			case "dirs-mixed": // Directories sorted among the files, not first
+				directories_first = false
+			case "pause": // Pause after each screen, like DOS /p
+				paginate = true
+			case "cs": // Case sensitive names, sorting or both
+				switch values {
+				case "", "all":
+					case_sensitive = true
+					case_sensitive_sort = true
+				case "name":
+					case_sensitive = true
+				case "sort":
+					case_sensitive_sort = true
+				default:
+					conditionalPrint(show_errors, "Unknown -cs=%s; use name, sort or all.\n", values)
+				}
 			case "b+":
 				bare = true
 				include_path = true
@@ -216,6 +517,18 @@ func parseCmdLine() {
 				size_calculations = false
 				directory_header = false
 				include_path = false
+			case "fast": // Names only, without each file's details
+				fast_mode = true
+				if !bare {
+					bare = true
+					size_calculations = false
+					directory_header = false
+				}
+			case "files-from": // Bare, relative paths for rsync --files-from or tar -T
+				bare = true
+				relative_paths = true
+				size_calculations = false
+				directory_header = false
 			case "c": // Change column definition for output
 				columnDef = values
 			case "d+":
@@ -235,19 +548,59 @@ func parseCmdLine() {
 			case "G+":
 				use_colors = true
 				use_enhanced_colors = true
+			case "l": // Like ls -l
+				columnDef = lsColumns
+				ls_dates = true
+				filesizes_format = SIZE_QUANTA
+				directories_first = false
+				size_calculations = false
+				ls_style = true
 			case "ma": // Accessed Date
 				parseDateRange(values)
 				minmaxdatetype = "a"
 			case "mc": // Created Date
 				parseDateRange(values)
 				minmaxdatetype = "c"
+			case "mi": // Inode change time
+				parseDateRange(values)
+				minmaxdatetype = "i"
 			case "md": // Parse dates, compare to Time.IsZero()
 				parseDateRange(values)
 				minmaxdatetype = "m"
 			case "ms": // Parse sizes
-				parseSizeRange(values)
+				parseSizeRange(values, &minsize, &maxsize)
+			case "links-count": // Hard links, as -ms
+				parseSizeRange(values, &min_links, &max_links)
+			case "prefix-strip": // Paths shown
+				prefix_strip = values
+			case "prefix-add":
+				prefix_add = values
+			case "mine": // Entries in directories
+				min_entries = parseNonNegative(p, values)
+			case "maxe":
+				if max_entries = parseNonNegative(p, values); max_entries < 0 {
+					max_entries = math.MaxInt64
+				}
+			case "mz": // Compressed sizes of archive members
+				parseSizeRange(values, &min_compressed, &max_compressed)
 			case "r":
 				recurse_directories = true
+			case "depth", "maxdepth": // How far -r goes
+				if n := parseNonNegative(p, values); n >= 0 {
+					max_depth = int(n)
+					recurse_directories = true
+				}
+			case "prune": // Leave out directories with nothing found under them
+				prune_empty = true
+			case "sep": // Field separator; accepts escapes like \t
+				field_separator = values
+				if unquoted, err := strconv.Unquote(`"` + values + `"`); err == nil {
+					field_separator = unquoted
+				}
+			case "since": // List only changes relative to a snapshot
+				since_file = values
+			case "snapshot": // Save metadata of what's listed
+				snapshot_file = values
 			case "sc": // Use commas (local sep) in file sizes
 				filesizes_format = SIZE_SEPARATOR
 			case "sh": // Use GB,TB, etc. in file sizes
@@ -255,33 +608,326 @@ func parseCmdLine() {
 			case "sr": // Standard default sizes - bytes with no formatting
 				filesizes_format = SIZE_NATURAL
 			case "t":
-				listfiles = false
+				totals_only = true
 			case "tc": // Case-sensitive search
 				text_search_type = SEARCH_CASE
-				text_regex = regexp.MustCompile(values)
+				search_text = values
 			case "ti": // Case-insensitive search
 				text_search_type = SEARCH_NOCASE
-				text_regex = regexp.MustCompile("(?i)" + values)
+				search_text = values
+			case "ts": // Smart case: insensitive unless there's an upper case letter
+				text_search_type = SEARCH_NOCASE
+				search_text = values
+				smart_case = true
 			case "tr": // Regex search
 				text_search_type = SEARCH_REGEX
-				text_regex = regexp.MustCompile(values)
+				search_text = values
+			case "format":
+				switch values {
+				case "json":
+					output_json = true
+				case "text":
+					output_json = false
+				default:
+					if strings.Contains(values, "{{") {
+						parseOutputTemplate(values)
+						break
+					}
+					conditionalPrint(true, "Unknown format %s; use json, text or a template.\n", values)
+					os.Exit(1)
+				}
+			case "all-matches": // No folding of repeated match records
+				all_matches = true
+			case "show-contents": // What's in each directory listed
+				show_contents = true
+				if len(values) > 0 {
+					if n := parseNonNegative(p, values); n >= 0 {
+						show_contents_max = int(n)
+					}
+				}
+			case "rollup": // CSV of files added per directory per month
+				rollup = true
+				rollup_modified = values == "modified"
+			case "precision": // Fractions of a second in times
+				if values == "ms" || values == "us" || values == "ns" || values == "s" {
+					time_precision = values
+				} else {
+					conditionalPrint(show_errors, "Unknown -precision=%s; use s, ms, us or ns.\n", values)
+				}
+			case "age": // Which time the d column counts from
+				switch values {
+				case "m", "modified":
+					age_from = "m"
+				case "c", "created":
+					age_from = "c"
+				case "a", "accessed":
+					age_from = "a"
+				default:
+					conditionalPrint(show_errors, "Unknown -age=%s; use modified, created or accessed.\n", values)
+				}
+			case "linkto": // Symlinks pointing at matching paths
+				if matcher, err := glob.Compile(values); err == nil {
+					linkto_matcher = matcher
+				} else {
+					conditionalPrint(true, "Invalid -linkto pattern %s: %s\n", values, err.Error())
+					os.Exit(1)
+				}
+			case "recent": // Newest files, with paths
+				parseRecent(values)
+			case "search-window": // Bytes searched at a time
+				if n := parseNonNegative(p, values); n >= searchOverlap {
+					search_window = int(n)
+				} else if n >= 0 {
+					conditionalPrint(show_errors, "search-window must be at least %d.\n", searchOverlap)
+				}
+			case "size-colors", "size-colors+", "size-colors-": // Big files' sizes stand out
+				size_colors = !strings.HasSuffix(p, "-")
+			case "wide", "w": // Names across the screen, DOS /w
+				wide_view = true
+			case "unbuffered": // Each line as soon as it's ready
+				unbuffered = true
+			case "explain": // What the flags came to, without listing
+				explain = true
+			case "audit": // Report on files that may hold secrets
+				parseAudit(values)
+			case "sensitive": // Only files that may hold secrets
+				sensitive_only = true
+			case "nogenerated": // No minified, generated or lock files
+				skip_generated = true
+				addGeneratedPatterns(values)
+			case "lang", "language": // Only code in these languages
+				languages = strings.Split(values, ",")
+			case "tt": // Only search these types or extensions
+				parseSearchTypes(values)
+			case "tw": // Whole words only
+				whole_word = true
+			case "tm": // Multiline: patterns span lines
+				multiline = true
+				if len(values) > 0 {
+					if n := parseNonNegative(p, values); n >= 0 {
+						multiline_max = n
+					}
+				}
+			case "tf", "tfi": // Any of the patterns in a file
+				patterns_file = values
+				text_search_type = SEARCH_CASE
+				if p == "tfi" {
+					text_search_type = SEARCH_NOCASE
+				}
+			case "tx": // Hex bytes search
+				pattern, err := parseHexPattern(values)
+				if err != nil {
+					conditionalPrint(true, "Invalid hex search %s: %s\n", values, err.Error())
+					os.Exit(1)
+				}
+				text_search_type = SEARCH_HEX
+				hex_pattern = pattern
+			case "timeout": // Per directory, for network file systems
+				if d, err := time.ParseDuration(values); err == nil {
+					dir_timeout = d
+				} else {
+					conditionalPrint(show_errors, "Invalid timeout: %s - %s\n", values, err.Error())
+				}
+			case "cache": // Reuse what was read last time, if it hasn't changed
+				use_cache = true
+				if len(values) > 0 {
+					if d, err := time.ParseDuration(values); err == nil {
+						cache_max_age = d
+					} else {
+						conditionalPrint(show_errors, "Invalid cache: %s - %s\n", values, err.Error())
+					}
+				}
+			case "number": // Number the files listed
+				switch values {
+				case "", "all":
+					number_rows = "all"
+				case "dir":
+					number_rows = "dir"
+				default:
+					conditionalPrint(true, "Unknown number %s; use all or dir.\n", values)
+					os.Exit(1)
+				}
+			case "pick": // From the last -number listing
+				pick_rows = values
+			case "smart-columns": // Leave out blank columns
+				smart_columns = true
+			case "first": // Stop at the first match
+				first_match = true
+				max_results = 1
+			case "max": // Stop after this many results
+				max_results = parseNonNegative(p, values)
+			case "watch": // A live summary
+				watch_mode = true
+			case "interval": // The most often -watch prints
+				if d, err := time.ParseDuration(values); err == nil && d > 0 {
+					watch_interval = d
+				} else {
+					conditionalPrint(true, "Invalid interval: %s; use a duration like 30s or 2m.\n", values)
+					os.Exit(1)
+				}
+			case "maxtime": // For the whole run
+				if d, err := time.ParseDuration(values); err == nil && d > 0 {
+					max_run_time = d
+				} else {
+					conditionalPrint(true, "Invalid maxtime: %s; use a duration like 30s or 2m.\n", values)
+					os.Exit(1)
+				}
+			case "retries":
+				if n := parseNonNegative(p, values); n >= 0 {
+					dir_retries = int(n)
+				}
+			case "batch": // Directory entries read at once
+				if n := parseNonNegative(p, values); n >= 0 {
+					dir_batch = int(n)
+				}
+			case "exec", "copyto", "moveto", "extractto":
+				if len(values) == 0 {
+					conditionalPrint(true, "-%s needs a value: -%s=...\n", p, p)
+					os.Exit(1)
+				}
+				addAction(p, values)
+			case "trash":
+				addAction(p, "")
+			case "dry-run":
+				dry_run = true
+			case "confirm":
+				if values == "once" {
+					confirm_mode = "once"
+				} else {
+					confirm_mode = "each"
+				}
+			case "trashcan": // List the trash instead
+				trashcan = true
+				columnDef = trashColumns
+			case "unordered": // Fastest, but the order varies from run to run
+				unordered = true
+			case "clones": // Data shared with other files
+				clones = true
+			case "verify-sidecars":
+				verify_sidecars = true
 			case "version", "v":
-				fmt.Println(versionDate)
+				if values == "json" {
+					printVersionJSON()
+				} else {
+					fmt.Fprintln(output, versionDate)
+				}
 				os.Exit(0)
+			case "dirignore", "dirignore+", "dirignore-":
+				use_dirignore = !strings.HasSuffix(p, "-")
+			case "dirconf", "dirconf+", "dirconf-":
+				use_treeconfig = !strings.HasSuffix(p, "-")
 			case "exclude", "x":
 				exclude_exts = strings.Split(strings.ToUpper(values), ",")
+			case "xd": // Directories not to list or go into
+				exclude_dirs = append(exclude_dirs, values) // Split with the masks, braces and all
 			case "z":
 				listInArchives = true
+				switch values {
+				case "", "all":
+					archives_top_only = false
+				case "top":
+					archives_top_only = true
+				default:
+					conditionalPrint(true, "-z= takes top or all, not %s\n", values)
+					os.Exit(1)
+				}
+			case "archive-order":
+				switch values {
+				case "first", "last", "mixed":
+					archive_order = values
+				default:
+					conditionalPrint(true, "-archive-order= takes first, last or mixed, not %s\n", values)
+					os.Exit(1)
+				}
+			case "portable": // Already seen, before the config file was read
+			case "lenient": // Ignore unknown flags
+				lenient = true
+			default:
+				unknown_flags = append(unknown_flags, s)
 			}
 		} else {
 			parseFileName(s)
 		}
 	}
+	reportConfigErrors()
+	checkUnknownFlags()
+	if verify_sidecars && !strings.Contains(columnDef, COLUMN_CHECKSUM) {
+		columnDef = COLUMN_CHECKSUM + "  " + columnDef
+	}
+	if clones && !strings.Contains(columnDef, COLUMN_SHARED) {
+		columnDef = COLUMN_SHARED + "  " + columnDef
+	}
+	if len(since_file) > 0 && !strings.Contains(columnDef, COLUMN_CHANGE) {
+		columnDef = COLUMN_CHANGE + "  " + columnDef
+	}
+	if len(number_rows) > 0 && !strings.Contains(columnDef, COLUMN_ROW) {
+		columnDef = COLUMN_ROW + "  " + columnDef
+	}
+	if trashcan {
+		startTrashListing()
+	}
+	if recent {
+		applyRecent()
+	}
+	if output_json || rollup { // Nothing but the JSON or CSV
+		size_calculations = false
+		directory_header = false
+	}
+	if skip_generated {
+		compileGeneratedPatterns()
+	}
+	if len(member_pattern) > 0 { // Once -cs is known
+		pattern := ternaryString(case_sensitive, member_pattern, strings.ToUpper(member_pattern))
+		if m, err := glob.Compile(pattern); err == nil {
+			member_matcher = m
+		} else {
+			conditionalPrint(true, "Invalid -zhas pattern %s: %s\n", member_pattern, err.Error())
+			os.Exit(1)
+		}
+	}
+	var excluded []string
+	for _, values := range exclude_dirs {
+		excluded = append(excluded, splitMasks(values)...)
+	}
+	exclude_dirs = excluded
+	for _, pattern := range exclude_dirs {
+		if m, err := glob.Compile(ternaryString(case_sensitive, pattern, strings.ToUpper(pattern))); err == nil {
+			exclude_matchers = append(exclude_matchers, m)
+		} else {
+			conditionalPrint(true, "Invalid -xd pattern %s: %s\n", pattern, err.Error())
+			os.Exit(1)
+		}
+	}
+	if archives_only { // Lists the archives instead of their members
+		listInArchives = false
+	}
+	if ls_style && !recurse_directories { // ls only names directories when listing several
+		directory_header = false
+	}
+	buildTextSearch()
+	count_matches = sortby.field == SORT_RELEVANCE || len(search_patterns) > 0 // -tf needs every match
+	owner_needed = sortby.field == SORT_OWNER || sort_tiebreak == SORT_OWNER || group_by == GROUP_OWNER || audit_secrets
+	for _, spec := range columnSpecs() {
+		if spec.column == COLUMN_OWNER[0] || spec.column == COLUMN_GROUP[0] {
+			owner_needed = true
+		} else if tmpl, found := customColumns[spec.column]; found && tmpl.Tree != nil {
+			owner_needed = owner_needed || strings.Contains(tmpl.Tree.Root.String(), ".Owner") || strings.Contains(tmpl.Tree.Root.String(), ".Group")
+		}
+	}
+	if output_template != nil { // -format= may use them, or the columns
+		owner_needed = true
+	}
+	if fast_mode {
+		applyFast()
+	}
 	if haveGlobber {
-		mask := file_mask
-		if !case_sensitive {
-			mask = strings.ToUpper(mask)
+		var masks []string
+		for _, mask := range file_masks {
+			masks = append(masks, splitMasks(mask)...)
+		}
+		file_masks = masks
+		for _, mask := range file_masks {
+			matchers = append(matchers, glob.MustCompile(ternaryString(case_sensitive, mask, strings.ToUpper(mask))))
 		}
-		matcher = glob.MustCompile(mask)
 	}
 }
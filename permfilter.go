@@ -0,0 +1,129 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -perm=: find-style permission filters.  The mode is octal or symbolic, like chmod's: u+s, g+w,
+// o=r, a+x, ug+rw,o-w.  A leading - needs all of its bits set, a leading / any of them; otherwise
+// the permissions must be exactly the mode.
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+type permFilter struct {
+	match byte   // '=' exactly, '-' all of bits, '/' any of bits
+	bits  uint32 // As chmod numbers them, setuid, setgid and sticky included
+}
+
+var perm_filters []permFilter // All must be met
+
+const (
+	PERM_SETUID = 04000
+	PERM_SETGID = 02000
+	PERM_STICKY = 01000
+)
+
+func parsePermFilter(value string) (permFilter, error) {
+	filter := permFilter{match: '='}
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "/") {
+		filter.match = value[0]
+		value = value[1:]
+	}
+	if n, err := strconv.ParseUint(value, 8, 32); err == nil && n <= 07777 {
+		filter.bits = uint32(n)
+		return filter, nil
+	}
+	for _, clause := range strings.Split(value, ",") {
+		op := strings.IndexAny(clause, "+-=")
+		if op < 0 {
+			return filter, fmt.Errorf("%s has no +, - or =", clause)
+		}
+		who := uint32(0)
+		for _, c := range clause[:op] {
+			switch c {
+			case 'u':
+				who |= 04700
+			case 'g':
+				who |= 02070
+			case 'o':
+				who |= 01007
+			case 'a':
+				who |= 07777
+			default:
+				return filter, fmt.Errorf("unknown class %c in %s; use u, g, o or a", c, clause)
+			}
+		}
+		if who == 0 {
+			who = 07777
+		}
+		perms := uint32(0)
+		for _, c := range clause[op+1:] {
+			switch c {
+			case 'r':
+				perms |= 0444
+			case 'w':
+				perms |= 0222
+			case 'x', 'X':
+				perms |= 0111
+			case 's':
+				perms |= PERM_SETUID | PERM_SETGID
+			case 't':
+				perms |= PERM_STICKY
+			default:
+				return filter, fmt.Errorf("unknown permission %c in %s; use r, w, x, s or t", c, clause)
+			}
+		}
+		switch clause[op] {
+		case '+':
+			filter.bits |= who & perms
+		case '-':
+			filter.bits &^= who & perms
+		case '=':
+			filter.bits = filter.bits&^who | who&perms
+		}
+	}
+	return filter, nil
+}
+
+// The mode as chmod numbers it.
+func unixPermissions(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= PERM_SETUID
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= PERM_SETGID
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= PERM_STICKY
+	}
+	return bits
+}
+
+func (p permFilter) matches(mode fs.FileMode) bool {
+	bits := unixPermissions(mode)
+	switch p.match {
+	case '-':
+		return bits&p.bits == p.bits
+	case '/':
+		return p.bits == 0 || bits&p.bits != 0
+	}
+	return bits == p.bits
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Portable mode: dir keeps its own files - the config file, the -cache listings and the -number
+// rows - in the directory the executable is in, rather than under the user's home, for running
+// from a USB stick or a jump host where nothing should be left behind.  It's on with -portable,
+// or when there's a dir.conf beside the executable.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var portable bool // Set before the config file is read, so -portable can't come from an alias
+
+// True if -portable is on the command line, or the executable has a dir.conf beside it.
+func portableRequested(args []string) bool {
+	for _, a := range args {
+		if strings.TrimLeft(a, "-") == "portable" && strings.HasPrefix(a, "-") {
+			return true
+		}
+	}
+	if dir := executableDir(); len(dir) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, "dir.conf")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// The directory the executable is in, symlinks followed, or "" if it can't be told.
+func executableDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe)
+}
+
+// Where dir keeps its files of a kind: a dir directory under the user directory given, as
+// os.UserCacheDir, or the executable's directory when portable.  "" if there's nowhere.
+func filesDir(userDir func() (string, error)) string {
+	if portable {
+		return executableDir()
+	}
+	dir, err := userDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dir")
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -prune: recursed listings leave out directories with nothing found under them.  Whether a
+// directory has anything isn't known until it has been walked, so with -prune a directory's
+// subdirectories are listed first and what they print is held until the directory's own rows,
+// with the empty ones taken out, have been printed.
+
+import (
+	"bytes"
+	"path/filepath"
+)
+
+var prune_empty = false // -prune
+
+// Walks the directories and archives under target, holding what they print, and takes the
+// directories that turned out to have nothing out of ls.  Returns what was held, in order.
+func descendHeld(target string, descents []descent, ls *ListingSet) []byte {
+	var held bytes.Buffer
+	found := map[string]bool{}
+	screen := setOutput(&held)
+	defer setOutput(screen)
+	for _, next := range descents {
+		if outOfTime() {
+			break
+		}
+		before := TotalFiles
+		descend(target, next)
+		found[next.name] = TotalFiles > before
+	}
+	kept := ls.MatchedFiles[:0]
+	for _, f := range ls.MatchedFiles {
+		if f.IsDir && !hasContent(f, found) {
+			ls.Directorycount--
+			continue
+		}
+		kept = append(kept, f)
+	}
+	ls.MatchedFiles = kept
+	return held.Bytes()
+}
+
+// Whether a directory's row stays with -prune: it had files listed somewhere under it.  When only
+// directories are listed (-d+), or it wasn't walked, as with -depth=, empty directories go.
+func hasContent(f fileitem, found map[string]bool) bool {
+	if matched, walked := found[f.Name]; walked && listfiles {
+		return matched
+	}
+	return f.EntryCount() != 0
+}
+
+// Lists a subdirectory or archive of target, one level deeper for directories.
+func descend(target string, next descent) {
+	depth := ternaryInt(next.archive, 0, 1) // An archive's members are in the directory it's in
+	dirDepth += depth
+	list_directory(filepath.Join(target, next.name), true, next.archive)
+	dirDepth -= depth
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -recent: the most recently modified files under a directory, newest first, with their paths.
+// Short for -r -o-d -b+ -d- and a limit.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRecentCount = 25
+
+var (
+	recent       bool
+	recent_count int // Files shown; 0 for all within recent_days
+	recent_days  int
+)
+
+// Parses -recent, -recent=N or -recent=Nd.
+func parseRecent(value string) {
+	recent = true
+	recent_count = defaultRecentCount
+	if len(value) == 0 {
+		return
+	}
+	days, isDays := strings.CutSuffix(strings.ToLower(value), "d")
+	n, err := strconv.Atoi(days)
+	if err != nil || n <= 0 {
+		conditionalPrint(true, "Invalid -recent=%s; use a count, like 50, or days, like 7d.\n", value)
+		recent_count = defaultRecentCount
+		return
+	}
+	if isDays {
+		recent_days, recent_count = n, 0
+	} else {
+		recent_count = n
+	}
+}
+
+// Sets the flags -recent stands for, once parsing is done.
+func applyRecent() {
+	recurse_directories = true
+	sortby = sortorder{SORT_DATE, false}
+	listdirectories = false
+	include_path = true
+	if recent_days > 0 {
+		mindate = time.Now().AddDate(0, 0, -recent_days)
+		minmaxdatetype = "m"
+	}
+}
+
+// Prints the newest of everything found.
+func printRecent(files []fileitem) {
+	sortFiles(files)
+	if recent_count > 0 && len(files) > recent_count {
+		files = files[:recent_count]
+		TotalFiles, TotalBytes = 0, 0 // The total line counts only those shown
+		for _, f := range files {
+			TotalFiles++
+			TotalBytes += f.Size
+		}
+	}
+	if directory_header {
+		if recent_days > 0 {
+			fmt.Fprintf(output, "\n   Modified in the last %d days under %s\n\n", recent_days, start_directory)
+		} else {
+			fmt.Fprintf(output, "\n   %d most recently modified under %s\n\n", len(files), start_directory)
+		}
+	}
+	printFiles(files)
+}
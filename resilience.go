@@ -0,0 +1,219 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Reading directories on flaky network mounts: -timeout= per directory, and -retries= with
+// backoff for transient errors, so one bad SMB/NFS directory is skipped rather than hanging
+// or ending the listing.
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+const retryBackoff = 250 * time.Millisecond // Doubled for each retry
+
+var (
+	dir_timeout   time.Duration // 0 for none
+	dir_retries   int
+	dir_batch     = 1024 // Entries read at once; 0 for all
+	errDirTimeout = errors.New("timed out")
+)
+
+// Reads a directory and the details of its entries, retrying transient failures.  Only the
+// entries keep accepts are returned, so a huge directory isn't all held at once.  keep may be
+// called again for the same entries on a retry.  A directory that timed out is only retried once
+// the read left hanging has finished, within the backoff.
+func readDirectory(target string, keep func(*fileitem) bool) ([]fileitem, error) {
+	for attempt := 0; ; attempt++ {
+		entries, reading, err := readDirectoryWithTimeout(target, keep)
+		delay := retryBackoff << attempt
+		retry := err != nil && attempt < dir_retries && isTransient(err)
+		if retry && err == errDirTimeout {
+			conditionalPrint(debug_messages, "Waiting up to %s for the read of %s to finish\n", delay, target)
+			retry = finishedWithin(reading, delay)
+		}
+		if !retry {
+			if err == errDirTimeout {
+				conditionalPrint(!bare || show_errors, "Skipped %s: timed out after %s.\n", target, dir_timeout)
+			} else if err != nil {
+				conditionalPrint(show_errors, "Could not read %s: %s\n", target, err.Error())
+			}
+			if err != nil {
+				noteUnreadableDirectory(target, err)
+			}
+			return entries, err
+		}
+		conditionalPrint(show_errors || debug_messages, "Retrying %s: %s\n", target, err.Error())
+		if err != errDirTimeout { // Its wait was the backoff
+			time.Sleep(delay)
+		}
+	}
+}
+
+// True if the channel is closed within the time.
+func finishedWithin(done <-chan struct{}, wait time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
+
+// A hung network read can't be interrupted, so it is done on a goroutine of its own, which is
+// left to finish on a time-out; reading is closed when it has.  That goroutine only reads: the
+// entries are made into fileitems and filtered here, on the walk's.
+func readDirectoryWithTimeout(target string, keep func(*fileitem) bool) (kept []fileitem, reading <-chan struct{}, err error) {
+	k := entryKeeper{target: target, keep: keep}
+	if dir_timeout <= 0 {
+		return k.finish(readRawEntries(target, k.take))
+	}
+	batches := make(chan []rawEntry)
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	var readErr error // Set before batches is closed
+	go func() {
+		defer close(finished)
+		readErr = readRawEntries(target, func(batch []rawEntry) bool {
+			select {
+			case batches <- batch:
+				return true
+			case <-stop:
+				return false
+			}
+		})
+		close(batches)
+	}()
+	defer close(stop)
+	deadline := time.After(dir_timeout)
+	for {
+		select {
+		case batch, open := <-batches:
+			if !open {
+				kept, _, err = k.finish(readErr)
+				return kept, finished, err
+			}
+			if !k.take(batch) {
+				kept, _, err = k.finish(nil)
+				return kept, finished, err
+			}
+		case <-deadline:
+			return nil, finished, errDirTimeout
+		}
+	}
+}
+
+// An entry as read, with its details unless stat_free.
+type rawEntry struct {
+	entry fs.DirEntry
+	info  fs.FileInfo
+	err   error // From reading info
+}
+
+// Reads the entries -batch= at a time, with their details, so the time-out covers both, handing
+// each batch to take until it returns false.  Touches nothing shared, so it can be left running.
+func readRawEntries(target string, take func([]rawEntry) bool) error {
+	pFile, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	defer pFile.Close()
+	for {
+		entries, err := pFile.ReadDir(dir_batch)
+		batch := make([]rawEntry, len(entries))
+		for i, e := range entries {
+			batch[i].entry = e
+			if !stat_free {
+				batch[i].info, batch[i].err = e.Info()
+			}
+		}
+		if len(batch) > 0 && !take(batch) {
+			return nil
+		}
+		if err == io.EOF || (err == nil && dir_batch <= 0) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Makes fileitems of the entries read and keeps those keep accepts.  Entries that vanish before
+// their details are read are dropped; others whose details can't be read are dropped and counted.
+type entryKeeper struct {
+	target             string
+	keep               func(*fileitem) bool
+	kept               []fileitem
+	unreadable, denied int
+	err                error // A transient failure reading details, which fails the read
+}
+
+// Takes a batch, returning false to stop reading.
+func (k *entryKeeper) take(batch []rawEntry) bool {
+	for _, r := range batch {
+		if stat_free {
+			if item := entryFileitem(r.entry, k.target); k.keep(&item) {
+				k.kept = append(k.kept, item)
+			}
+			continue
+		}
+		if r.err != nil {
+			if !errors.Is(r.err, fs.ErrNotExist) && isTransient(r.err) {
+				k.err = r.err
+				return false
+			}
+			if !errors.Is(r.err, fs.ErrNotExist) {
+				conditionalPrint(show_errors, "Could not read the details of %s: %s\n", r.entry.Name(), r.err.Error())
+				k.unreadable++
+				k.denied += ternaryInt(errors.Is(r.err, fs.ErrPermission), 1, 0)
+			}
+			continue
+		}
+		if item := makefileitem(fs.FileInfoToDirEntry(r.info), k.target); k.keep(&item) {
+			k.kept = append(k.kept, item)
+		}
+	}
+	return !outOfTime()
+}
+
+// What was kept, or the error that ended the read.
+func (k *entryKeeper) finish(err error) ([]fileitem, <-chan struct{}, error) {
+	if err == nil {
+		err = k.err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	noteUnreadableFiles(k.unreadable, k.denied)
+	return k.kept, nil, nil
+}
+
+func isTransient(err error) bool {
+	if err == errDirTimeout {
+		return true
+	}
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
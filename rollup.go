@@ -0,0 +1,81 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -rollup: a CSV of the files and bytes added to each directory each month, for capacity planning.
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	rollup          bool
+	rollup_modified bool // Date files by modification, even where creation times are known
+)
+
+type rollupKey struct {
+	dir   string
+	month string
+}
+
+// When the file was added, as far as can be told.
+func addedDate(f fileitem) time.Time {
+	if !rollup_modified && !f.Created.IsZero() {
+		return f.Created
+	}
+	return f.Modified
+}
+
+// Prints directory,month,files,bytes, by directory and then month, after a # comment line
+// with the schema and version.
+func printRollup(files []fileitem) {
+	type totals struct {
+		files int
+		bytes int64
+	}
+	rows := map[rollupKey]*totals{}
+	var keys []rollupKey
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		key := rollupKey{displayPath(f.Path), addedDate(f).Format("2006-01")}
+		if rows[key] == nil {
+			rows[key] = &totals{}
+			keys = append(keys, key)
+		}
+		rows[key].files++
+		rows[key].bytes += f.Size
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].dir != keys[j].dir {
+			return keys[i].dir < keys[j].dir
+		}
+		return keys[i].month < keys[j].month
+	})
+	fmt.Fprintf(output, "# dir rollup, schema %d, version %s\n", outputSchema, versionDate)
+	out := csv.NewWriter(output)
+	out.Write([]string{"directory", "month", "files", "bytes"})
+	for _, k := range keys {
+		out.Write([]string{k.dir, k.month, strconv.Itoa(rows[k].files), strconv.FormatInt(rows[k].bytes, 10)})
+	}
+	out.Flush()
+}
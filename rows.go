@@ -0,0 +1,157 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -number: the # column, numbering the files listed so they can be referred to, through the
+// whole listing or from 1 in each directory.  The numbered files are kept in the user cache
+// directory, so -pick= can act on some of them afterwards, e.g.
+//   dir -r -number "*.log"           then   dir -pick=3,7-9 -trash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type numberedRow struct {
+	Path      string `json:"path"` // Directory, or the archive for archive members
+	Name      string `json:"name"`
+	InArchive bool   `json:"inarchive,omitempty"`
+	IsDir     bool   `json:"isdir,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+var (
+	number_rows  string // "" for none, "all" through the listing, or "dir" from 1 in each
+	rowsNumbered int
+	numbered     []numberedRow // Row n is numbered[n-1], with "all"
+	pick_rows    string        // -pick=: numbers and ranges, e.g. 3,7-9
+)
+
+// Numbers the files about to be printed.
+func numberRows(files []fileitem) {
+	if number_rows == "dir" {
+		rowsNumbered = 0
+	}
+	for i := range files {
+		if relative_paths && files[i].InArchive { // Not printed
+			continue
+		}
+		rowsNumbered++
+		files[i].Row = rowsNumbered
+		if number_rows == "all" {
+			f := files[i]
+			numbered = append(numbered, numberedRow{f.Path, f.Name, f.InArchive, f.IsDir, f.Size})
+		}
+	}
+}
+
+// The # column.
+func (f fileitem) RowNumber() string {
+	return fmt.Sprintf("%4d", f.Row)
+}
+
+func numberedRowsPath() string {
+	dir := filesDir(os.UserCacheDir)
+	if len(dir) == 0 {
+		return ""
+	}
+	return filepath.Join(dir, "numbered.json")
+}
+
+// Keeps the numbered files for -pick=.  Per-directory numbers don't say which file is meant, so
+// only numbers through the whole listing are kept.
+func saveNumberedRows() {
+	if number_rows != "all" {
+		return
+	}
+	path := numberedRowsPath()
+	data, err := json.Marshal(numbered)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0600)
+	}
+	if err != nil {
+		conditionalPrint(show_errors, "Could not keep the numbered files: %s\n", err.Error())
+	}
+}
+
+// Parses -pick=, e.g. 3,7-9, into the row numbers, up to count, the rows there are.
+func parsePick(value string, count int) ([]int, error) {
+	var picked []int
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(strings.TrimPrefix(first, "#"))
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(last)
+		}
+		if err != nil || from < 1 || to < from {
+			return nil, fmt.Errorf("%s is not a number or range, like 3 or 7-9", part)
+		}
+		if to > count {
+			conditionalPrint(true, "There is no #%d; the last listing had %d.\n", ternaryInt(from > count, from, count+1), count)
+			to = count
+		}
+		for n := from; n <= to; n++ {
+			picked = append(picked, n)
+		}
+	}
+	return picked, nil
+}
+
+// Lists the picked files from the last numbered listing, and queues them for the actions.  Files on
+// disk are looked at again, and skipped if they've gone.
+func pickRows() {
+	var rows []numberedRow
+	data, err := os.ReadFile(numberedRowsPath())
+	if err == nil {
+		err = json.Unmarshal(data, &rows)
+	}
+	if err != nil {
+		conditionalPrint(true, "No numbered listing to pick from; list with -number first.\n")
+		flushOutput()
+		os.Exit(1)
+	}
+	picked, err := parsePick(pick_rows, len(rows))
+	if err != nil {
+		conditionalPrint(true, "Invalid pick %s: %s\n", pick_rows, err.Error())
+		flushOutput()
+		os.Exit(1)
+	}
+	var files []fileitem
+	for _, n := range picked {
+		row := rows[n-1]
+		f := fileitem{Path: row.Path, Name: row.Name, InArchive: row.InArchive, IsDir: row.IsDir, Size: row.Size, Row: n}
+		if !row.InArchive {
+			fi, err := os.Lstat(filepath.Join(row.Path, row.Name))
+			if err != nil {
+				conditionalPrint(true, "#%d %s: %s\n", n, row.Name, err.Error())
+				continue
+			}
+			f.Size, f.Modified, f.Mode, f.IsDir = fi.Size(), fi.ModTime(), fi.Mode(), fi.IsDir()
+		}
+		fmt.Fprintf(output, "%s  %s\n", f.RowNumber(), displayPath(filepath.Join(f.Path, f.Name)))
+		files = append(files, f)
+	}
+	queueForActions(files)
+}
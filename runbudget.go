@@ -0,0 +1,93 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -maxtime=: a time limit for the whole run.  When it's reached, the walk and any text search
+// stop where they are, and what was found so far is printed with a notice that it's incomplete.
+// -max= stops them the same way once that many results are found, and -first at the first.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	max_run_time time.Duration // 0 for none
+	runContext   = context.Background()
+	cancelRun    = context.CancelFunc(func() {})
+	max_results  int64       // -max=: 0 for no limit.  -first is 1
+	first_match  bool        // -first: stop at the first match
+	resultsTaken int64       // Toward max_results
+	limitReached atomic.Bool // max_results have been found
+)
+
+// Starts the clock.  Called just before the walk.
+func startRunBudget() {
+	if max_run_time > 0 {
+		runContext, cancelRun = context.WithTimeout(context.Background(), max_run_time)
+	}
+}
+
+// True once -maxtime has passed, or -max= results have been found.
+func outOfTime() bool {
+	return runContext.Err() != nil || limitReached.Load()
+}
+
+// For -max= and -first: keeps only as many of what was found as are still wanted, and stops the
+// run once there are enough.
+func limitResults(ls *ListingSet) {
+	if max_results <= 0 || len(ls.MatchedFiles) == 0 {
+		return
+	}
+	if wanted := max_results - resultsTaken; int64(len(ls.MatchedFiles)) > wanted {
+		ls.MatchedFiles = ls.MatchedFiles[:wanted]
+		ls.Filecount, ls.Directorycount, ls.Bytesfound = 0, 0, 0
+		for _, f := range ls.MatchedFiles {
+			if f.IsDir {
+				ls.Directorycount++
+			} else {
+				ls.Filecount++
+				ls.Bytesfound += f.Size
+			}
+		}
+	}
+	resultsTaken += int64(len(ls.MatchedFiles))
+	if resultsTaken >= max_results {
+		limitReached.Store(true)
+	}
+}
+
+// Says the results are incomplete, if they are.  Output for other programs - names only, JSON,
+// CSV - gets it on stderr instead, so it isn't taken for a file.
+func printTruncationNotice() {
+	if runContext.Err() == nil && (!limitReached.Load() || first_match) {
+		return
+	}
+	out := output
+	if bare || output_json || rollup {
+		flushOutput() // So it comes after the results
+		out = os.Stderr
+	}
+	if runContext.Err() != nil {
+		fmt.Fprintf(out, "\n   Stopped after -maxtime=%s; these results are incomplete.\n", max_run_time)
+	} else {
+		fmt.Fprintf(out, "\n   Results truncated at -max=%d; there may be more.\n", max_results)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -tf: search for any of a list of patterns read from a file, e.g. indicators of compromise,
+// noting which were found in each file.
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	patterns_file   string           // -tf, loaded once all flags are parsed
+	search_patterns []*regexp.Regexp // Each pattern alone, anchored, to tell which one matched
+	pattern_sources []string         // The lines they came from, as shown in the P column
+)
+
+// Reads one pattern per line: literal text, or a regular expression if it starts with re:
+// Blank lines and lines starting with # are skipped.  All are combined into text_regex.
+func loadSearchPatterns(filename string, ignoreCase bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	prefix := ternaryString(ignoreCase, "(?i)", "") + multilineFlags()
+	var alternatives []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expr, isRegex := strings.CutPrefix(line, "re:")
+		if !isRegex {
+			expr = regexp.QuoteMeta(line)
+		}
+		expr = wholeWord(expr)
+		anchored, err := regexp.Compile(prefix + "^(?:" + expr + ")$")
+		if err != nil {
+			return err
+		}
+		search_patterns = append(search_patterns, anchored)
+		pattern_sources = append(pattern_sources, line)
+		alternatives = append(alternatives, "(?:"+expr+")")
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if len(alternatives) == 0 {
+		text_regex = regexp.MustCompile("$^") // Nothing to find
+		return nil
+	}
+	text_regex, err = regexp.Compile(prefix + strings.Join(alternatives, "|"))
+	return err
+}
+
+// Records which patterns a match came from, for -tf.
+func (f *fileitem) notePattern(match []byte) {
+	for i, p := range search_patterns {
+		if p.Match(match) && !slices.Contains(f.Patterns, pattern_sources[i]) {
+			f.Patterns = append(f.Patterns, pattern_sources[i])
+		}
+	}
+}
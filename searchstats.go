@@ -0,0 +1,82 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Counts for the summary after a content search, so the coverage of a sweep can be judged.
+// Searches run in parallel, so the counts are atomic.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var searchStats struct {
+	started      time.Time
+	scanned      atomic.Int64 // Files opened for searching
+	scannedBytes atomic.Int64 // Their sizes
+	archives     atomic.Int64 // Archives whose members were searched, including ones inside others
+	matched      atomic.Int64
+	matchedBytes atomic.Int64
+	matches      atomic.Int64
+	bytesRead    atomic.Int64 // Bytes searched, including text extracted from PDFs and Office files
+	tooLarge     atomic.Int64 // Archive members over the size searched
+	skippedType  atomic.Int64 // Not a -tt type
+	unreadable   atomic.Int64
+	timedOut     atomic.Int64 // The PDF helper took too long
+}
+
+func printSearchStats() {
+	matches := ""
+	if count_matches { // Otherwise the search stops at the first in each file
+		matches = fmt.Sprintf(", with %d matches", searchStats.matches.Load())
+	}
+	fmt.Fprintf(output, "   Searched %d files (%s bytes, %s read) in %s: %d matched (%s bytes)%s.\n", searchStats.scanned.Load(),
+		strings.TrimSpace(FileSizeToString(searchStats.scannedBytes.Load())), strings.TrimSpace(FileSizeToString(searchStats.bytesRead.Load())),
+		time.Since(searchStats.started).Round(time.Millisecond), searchStats.matched.Load(),
+		strings.TrimSpace(FileSizeToString(searchStats.matchedBytes.Load())), matches)
+	if archives := searchStats.archives.Load(); archives > 0 {
+		fmt.Fprintf(output, "   Members of %d archives were searched, to a depth of %d.\n", archives, archive_max_depth)
+	}
+	if scanned := searchStats.scanned.Load(); scanned > 0 { // How selective the search was
+		fmt.Fprintf(output, "   %s of the files and %s of the bytes searched matched.\n", percentOf(searchStats.matched.Load(), scanned),
+			percentOf(searchStats.matchedBytes.Load(), searchStats.scannedBytes.Load()))
+	}
+	var skipped []string
+	for _, s := range []struct {
+		count  int64
+		reason string
+	}{{searchStats.tooLarge.Load(), "too large"}, {searchStats.skippedType.Load(), "not a -tt type"},
+		{searchStats.unreadable.Load(), "unreadable"}, {searchStats.timedOut.Load(), "timed out"}} {
+		if s.count > 0 {
+			skipped = append(skipped, fmt.Sprintf("%d %s", s.count, s.reason))
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(output, "   Skipped %s.\n", strings.Join(skipped, ", "))
+	}
+}
+
+// n as a percentage of total, e.g. "12.5%".
+func percentOf(n int64, total int64) string {
+	if total == 0 {
+		return "0%"
+	}
+	return strconv.FormatFloat(100*float64(n)/float64(total), 'f', 1, 64) + "%"
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Files likely to hold secrets - private keys, keystores, .env files, credentials, kubeconfigs -
+// by name, for the ! column and -sensitive, to sweep a shared drive for what shouldn't be there.
+// The config file's sensitive setting adds patterns.  Patterns with a / match the end of the path.
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/glob"
+)
+
+type sensitivePattern struct {
+	pattern string
+	kind    string // Shown in the ! column
+	matcher glob.Glob
+}
+
+var (
+	sensitive_only    bool // -sensitive
+	sensitivePatterns = []sensitivePattern{
+		{pattern: "id_rsa", kind: "key"}, {pattern: "id_dsa", kind: "key"}, {pattern: "id_ecdsa", kind: "key"},
+		{pattern: "id_ed25519", kind: "key"}, {pattern: "*.key", kind: "key"}, {pattern: "*.pem", kind: "key"},
+		{pattern: "*.ppk", kind: "key"}, {pattern: "*.p8", kind: "key"},
+		{pattern: "*.pfx", kind: "keystore"}, {pattern: "*.p12", kind: "keystore"}, {pattern: "*.jks", kind: "keystore"},
+		{pattern: "*.keystore", kind: "keystore"}, {pattern: "*.kdbx", kind: "keystore"},
+		{pattern: ".env", kind: "env"}, {pattern: ".env.*", kind: "env"}, {pattern: "*.env", kind: "env"},
+		{pattern: "credentials", kind: "creds"}, {pattern: "credentials.json", kind: "creds"}, {pattern: ".netrc", kind: "creds"},
+		{pattern: ".pgpass", kind: "creds"}, {pattern: ".htpasswd", kind: "creds"}, {pattern: ".git-credentials", kind: "creds"},
+		{pattern: ".pypirc", kind: "creds"}, {pattern: "secrets.{yml,yaml,json}", kind: "creds"},
+		{pattern: "kubeconfig", kind: "kubeconfig"}, {pattern: "*.kubeconfig", kind: "kubeconfig"},
+		{pattern: ".kube/config", kind: "kubeconfig"},
+	}
+	compileSensitive sync.Once
+)
+
+// Adds comma separated patterns from the config file.
+func addSensitivePatterns(value string) {
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); len(pattern) > 0 {
+			sensitivePatterns = append(sensitivePatterns, sensitivePattern{pattern: pattern, kind: "listed"})
+		}
+	}
+}
+
+// What kind of secret the file may hold, going by its name, or "" if it doesn't look like one.
+func (f fileitem) SensitiveKind() string {
+	compileSensitive.Do(func() {
+		for i, p := range sensitivePatterns {
+			pattern := strings.ToUpper(ternaryString(strings.Contains(p.pattern, "/"), "*/"+p.pattern, p.pattern))
+			if matcher, err := glob.Compile(pattern); err == nil {
+				sensitivePatterns[i].matcher = matcher
+			} else {
+				conditionalPrint(show_errors, "Invalid sensitive pattern %s: %s\n", p.pattern, err.Error())
+			}
+		}
+	})
+	if f.IsDir {
+		return ""
+	}
+	name := strings.ToUpper(f.Name)
+	path := strings.ToUpper(filepath.ToSlash(filepath.Join(f.Path, f.Name)))
+	for _, p := range sensitivePatterns {
+		if p.matcher == nil {
+			continue
+		}
+		if strings.Contains(p.pattern, "/") && p.matcher.Match(path) || p.matcher.Match(name) {
+			return p.kind
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -show-contents: under each directory listed, what's in it and how big it all is, to confirm
+// what a cleanup like "dir -r node_modules -d+" would remove.
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	show_contents     bool
+	show_contents_max = 20 // Entries shown per directory
+)
+
+// Prints the directory's immediate contents, indented, then its total size including subdirectories.
+func printContents(f fileitem) {
+	dir := filepath.Join(f.Path, f.Name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read %s: %s\n", dir, err.Error())
+		return
+	}
+	var visible []fs.DirEntry
+	for _, e := range entries {
+		if listhidden || e.Name()[0] != '.' {
+			visible = append(visible, e)
+		}
+	}
+	for i, e := range visible {
+		if i == show_contents_max {
+			fmt.Fprintf(output, "          ... and %d more\n", len(visible)-i)
+			break
+		}
+		size := "<DIR>"
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			size = FileSizeToString(info.Size())
+		}
+		fmt.Fprintf(output, "          %14s  %s\n", size, e.Name())
+	}
+	files, total := treeTotals(dir)
+	fmt.Fprintf(output, "          %d entries; %d files (%s bytes) in all.\n", len(visible), files, strings.TrimSpace(FileSizeToString(total)))
+}
+
+var treeSizes = map[string]int64{} // Directory to treeTotals' bytes, for sorting with -ou
+
+// The files under dir, subdirectories included, and their bytes.
+func treeTotals(dir string) (int64, int64) {
+	var files, total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				files++
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return files, total
+}
+
+// For -ou: a directory's bytes, subdirectories included, or a file's size.  Directories in
+// archives are 0.
+func (f *fileitem) TreeSize() int64 {
+	if !f.IsDir {
+		return f.Size
+	} else if f.InArchive {
+		return 0
+	}
+	dir := filepath.Join(f.Path, f.Name)
+	if _, found := treeSizes[dir]; !found {
+		_, treeSizes[dir] = treeTotals(dir)
+	}
+	return treeSizes[dir]
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -size-colors: the size column in its own color past each threshold, so huge files stand out
+// in a long listing.  Thresholds come from the config file's size-color settings, or else the
+// defaults below.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type sizeColor struct {
+	min   int64  // Sizes from this up
+	style string // As in LS_COLORS
+}
+
+var (
+	size_colors      bool
+	sizeColors       = []sizeColor{{1 << 30, "01;31"}, {100 << 20, "01;33"}} // Largest first
+	configSizeColors bool                                                    // The config file has replaced the defaults
+)
+
+// Adds a threshold from a size-color setting.  The first replaces the defaults.
+func addSizeColor(size string, style string) error {
+	min, err := parseByteSize(size)
+	if err != nil {
+		return err
+	}
+	if !configSizeColors {
+		sizeColors, configSizeColors = nil, true
+		size_colors = true
+	}
+	sizeColors = append(sizeColors, sizeColor{min, style})
+	sort.Slice(sizeColors, func(i, j int) bool { return sizeColors[i].min > sizeColors[j].min })
+	return nil
+}
+
+// The color for the column if it's the size of a file past a threshold, or "".
+func (f fileitem) sizeColorString(column byte) string {
+	if !size_colors || !use_colors || f.IsDir || string(column) != COLUMN_FILESIZE {
+		return ""
+	}
+	for _, c := range sizeColors {
+		if f.Size >= c.min {
+			return fmt.Sprintf("\033[%sm", c.style)
+		}
+	}
+	return ""
+}
+
+// A number of bytes, optionally with K, M, G or T (powers of 1024) and B, e.g. 100M or 1.5GB.
+func parseByteSize(v string) (int64, error) {
+	s := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(v)), "B")
+	multiplier := int64(1)
+	if i := strings.IndexAny(s, "KMGT"); i >= 0 && i == len(s)-1 {
+		multiplier = int64(1) << (10 * (strings.IndexByte("KMGT", s[i]) + 1))
+		s = s[:i]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %s", v)
+	}
+	return int64(n * float64(multiplier)), nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Snapshots of listed metadata, so a later run can list only what changed.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type snapshotEntry struct {
+	Path     string      `json:"path"` // Absolute, including name
+	Size     int64       `json:"size"`
+	Modified time.Time   `json:"modified"`
+	Mode     fs.FileMode `json:"mode"`
+	IsDir    bool        `json:"isdir,omitempty"`
+}
+
+type snapshot struct {
+	Taken time.Time       `json:"taken"`
+	Root  string          `json:"root"`
+	Files []snapshotEntry `json:"files"`
+}
+
+var (
+	snapshot_file   string                   // -snapshot= destination
+	since_file      string                   // -since= source
+	snapshotRecords []snapshotEntry          // What this run will save
+	sinceSnapshot   snapshot                 // What we compare against
+	sinceEntries    map[string]snapshotEntry // sinceSnapshot.Files by path
+	walkedDirs      = map[string]bool{}      // Directories listed, to scope removals
+)
+
+func snapshotKey(f fileitem) string {
+	key, err := filepath.Abs(filepath.Join(f.Path, f.Name))
+	if err != nil {
+		return filepath.Join(f.Path, f.Name)
+	}
+	return key
+}
+
+func loadSinceSnapshot() {
+	data, err := os.ReadFile(since_file)
+	if err == nil {
+		err = json.Unmarshal(data, &sinceSnapshot)
+	}
+	if err != nil {
+		fmt.Fprintf(output, "Could not read snapshot %s: %s\n", since_file, err.Error())
+		os.Exit(1)
+	}
+	sinceEntries = make(map[string]snapshotEntry, len(sinceSnapshot.Files))
+	for _, e := range sinceSnapshot.Files {
+		sinceEntries[e.Path] = e
+	}
+}
+
+// Called for files that pass all other conditions.  Records for -snapshot, and
+// returns false if -since is set and the file is unchanged.
+func snapshotConditions(target fileitem) bool {
+	if target.InArchive || (len(snapshot_file) == 0 && sinceEntries == nil) {
+		return true
+	}
+	key := snapshotKey(target)
+	if len(snapshot_file) > 0 {
+		snapshotRecords = append(snapshotRecords, snapshotEntry{key, target.Size, target.Modified, target.Mode, target.IsDir})
+	}
+	return sinceEntries == nil || target.ChangeStatus() != ""
+}
+
+// "+" if added since the -since snapshot, "M" if modified, "" if unchanged or not comparing.
+// Directory times change with their contents, so directories are only ever added.
+func (f fileitem) ChangeStatus() string {
+	if sinceEntries == nil || f.InArchive {
+		return ""
+	}
+	old, found := sinceEntries[snapshotKey(f)]
+	if !found {
+		return "+"
+	}
+	if !f.IsDir && (old.Size != f.Size || !old.Modified.Equal(f.Modified) || old.Mode != f.Mode) {
+		return "M"
+	}
+	return ""
+}
+
+func markDirectoryWalked(target string) {
+	if abs, err := filepath.Abs(target); err == nil {
+		walkedDirs[abs] = true
+	}
+}
+
+// Prints what is gone since the -since snapshot, within the directories we walked,
+// then saves the -snapshot file if requested.
+func finishSnapshots() {
+	if sinceEntries != nil {
+		var removed []string
+		for path, e := range sinceEntries {
+			if !walkedDirs[filepath.Dir(path)] {
+				continue
+			}
+			if haveGlobber && !maskMatches(filepath.Base(path)) {
+				continue
+			}
+			if (e.IsDir && !listdirectories) || (!e.IsDir && !listfiles) {
+				continue
+			}
+			if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+				removed = append(removed, path)
+			}
+		}
+		sort.Strings(removed)
+		if len(removed) > 0 && !bare {
+			fmt.Fprintf(output, "\n   Removed since %s:\n\n", sinceSnapshot.Taken.Format("2006-01-02 15:04:05"))
+		}
+		for _, path := range removed {
+			fmt.Fprintln(output, ternaryString(bare, path, "-  "+path))
+		}
+		if size_calculations {
+			fmt.Fprintf(output, "   %4d Removed.\n", len(removed))
+		}
+	}
+	if len(snapshot_file) > 0 {
+		root, _ := filepath.Abs(start_directory)
+		data, err := json.MarshalIndent(snapshot{time.Now(), root, snapshotRecords}, "", " ")
+		if err == nil {
+			err = os.WriteFile(snapshot_file, data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(output, "Could not write snapshot %s: %s\n", snapshot_file, err.Error())
+		}
+	}
+}
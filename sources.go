@@ -0,0 +1,80 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Where listings come from.  The walk asks a fileSource for each directory's entries, so the
+// local disk is one source among possible others: anything with an io/fs file system - an
+// in-memory fstest.MapFS, an SFTP or S3 client's fs.FS - plugs in as an fsSource.
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Lists a directory, returning the entries keep accepts.  dir is as the walk names it: the start
+// directory, joined with the names of subdirectories.
+type fileSource interface {
+	ListDir(dir string, keep func(*fileitem) bool) ([]fileitem, error)
+}
+
+var source fileSource = diskSource{}
+
+// The local disk, with -timeout and -retries, and -cache if asked for.
+type diskSource struct{}
+
+func (diskSource) ListDir(dir string, keep func(*fileitem) bool) ([]fileitem, error) {
+	if cacheUsable() {
+		return readDirectoryCached(dir, keep)
+	}
+	return readDirectory(dir, keep)
+}
+
+// An io/fs file system, walked from root, which stands for its top directory.  Only the name,
+// size, modification time and mode come from it; details that need the disk, like owners and
+// link targets, are left blank.
+type fsSource struct {
+	fsys fs.FS
+	root string
+}
+
+func (s fsSource) ListDir(dir string, keep func(*fileitem) bool) ([]fileitem, error) {
+	name := "."
+	if rel, err := filepath.Rel(s.root, dir); err == nil && rel != "." {
+		name = path.Clean(filepath.ToSlash(rel))
+	}
+	if !fs.ValidPath(name) || strings.HasPrefix(name, "../") {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: fs.ErrInvalid}
+	}
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var kept []fileitem
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		item := fileitem{Path: dir, Name: info.Name(), Size: info.Size(), Modified: info.ModTime(), IsDir: info.IsDir(), Mode: info.Mode()}
+		if keep(&item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -format="{{.Name}} {{.Size}}": each file's line from a Go text/template over the fileitem, for
+// layouts the columns can't give.  Fields are the fileitem's - Name, Path, Size, Modified, Owner
+// and so on - and its methods, such as DisplayName and Extension.  Times are Go times, so
+// {{.Modified.Format "2006-01-02"}} works.
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+var output_template *template.Template // -format=, if it's a template
+
+// Helpers for templates, formatting as the columns do.
+var templateFuncs = template.FuncMap{
+	"size": FileSizeToString,
+	"date": formatTime,
+	"column": func(f *fileitem, column string) string {
+		if len(column) != 1 {
+			return ""
+		}
+		return f.ColumnValue(column[0])
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// Compiles the -format template; a mistake in it ends the run, before anything is listed.
+func parseOutputTemplate(text string) {
+	t, err := template.New("format").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		conditionalPrint(true, "Invalid -format template: %s\n", err.Error())
+		os.Exit(1)
+	}
+	output_template = t
+}
+
+// The file's line from the template.  An error, e.g. a field that doesn't exist, is shown in its place.
+func (f fileitem) templateOutput() string {
+	var out strings.Builder
+	if err := output_template.Execute(&out, &f); err != nil {
+		return fmt.Sprintf("%s: %s", f.Name, err.Error())
+	}
+	return out.String()
+}
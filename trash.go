@@ -0,0 +1,230 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -trashcan: list the user's trash - the freedesktop.org Trash on Linux and BSD, ~/.Trash on
+// macOS, the Recycle Bin on Windows - with where each file came from and when it was deleted.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const trashColumns = "D   s   n   O"
+
+type trashEntry struct {
+	origin  string    // Full path the file was deleted from
+	deleted time.Time // When it was deleted
+}
+
+var (
+	trashcan  bool                  // -trashcan
+	trashRoot string                // The directory holding trashed files
+	trashInfo map[string]trashEntry // By name in trashRoot
+)
+
+// Where trashed files are kept.  Only the home (or system drive) trash; not those on other volumes.
+func trashDirectory() string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, ".Trash")
+	case "windows":
+		u, err := user.Current()
+		if err != nil {
+			return ""
+		}
+		drive := os.Getenv("SystemDrive")
+		if len(drive) == 0 {
+			drive = "C:"
+		}
+		return filepath.Join(drive+`\`, "$Recycle.Bin", u.Uid) // Uid is the SID
+	}
+	data := os.Getenv("XDG_DATA_HOME")
+	if len(data) == 0 {
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "Trash", "files")
+}
+
+// Switches the listing to the trash, reading what is known about each file.
+func startTrashListing() {
+	trashRoot = trashDirectory()
+	if fi, err := os.Stat(trashRoot); err != nil || !fi.IsDir() {
+		conditionalPrint(true, "No trash found at %s\n", trashRoot)
+		os.Exit(1)
+	}
+	start_directory = trashRoot
+	trashInfo = map[string]trashEntry{}
+	switch runtime.GOOS {
+	case "darwin": // Original locations are kept in .DS_Store, which we don't read.
+	case "windows":
+		loadRecycleBinInfo(trashRoot)
+	default:
+		loadTrashInfoFiles(filepath.Join(filepath.Dir(trashRoot), "info"))
+	}
+}
+
+// freedesktop.org: info/name.trashinfo has Path= (URL-escaped) and DeletionDate= (local time) for files/name.
+func loadTrashInfoFiles(infoDir string) {
+	entries, err := os.ReadDir(infoDir)
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read trash info %s: %s\n", infoDir, err.Error())
+		return
+	}
+	for _, e := range entries {
+		name, isInfo := strings.CutSuffix(e.Name(), ".trashinfo")
+		if !isInfo {
+			continue
+		}
+		file, err := os.Open(filepath.Join(infoDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var entry trashEntry
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key, value, _ := strings.Cut(scanner.Text(), "=")
+			switch key {
+			case "Path":
+				if unescaped, err := url.PathUnescape(value); err == nil {
+					value = unescaped
+				}
+				entry.origin = value
+			case "DeletionDate":
+				entry.deleted, _ = time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+			}
+		}
+		file.Close()
+		trashInfo[name] = entry
+	}
+}
+
+// Windows: each $Rxxxxxx.ext has a $Ixxxxxx.ext holding a version, the size, the deletion FILETIME
+// and the original path - fixed at 260 UTF-16 characters in version 1, length-prefixed in version 2.
+func loadRecycleBinInfo(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read Recycle Bin %s: %s\n", dir, err.Error())
+		return
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "$I") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil || len(data) < 24 {
+			continue
+		}
+		filetime := int64(binary.LittleEndian.Uint64(data[16:24]))
+		entry := trashEntry{deleted: time.Unix(0, (filetime-116444736000000000)*100)}
+		var path []byte
+		if binary.LittleEndian.Uint64(data[0:8]) == 1 {
+			path = data[24:]
+		} else if len(data) >= 28 {
+			n := int(binary.LittleEndian.Uint32(data[24:28])) * 2
+			if 28+n <= len(data) {
+				path = data[28 : 28+n]
+			}
+		}
+		chars := make([]uint16, 0, len(path)/2)
+		for i := 0; i+1 < len(path); i += 2 {
+			c := binary.LittleEndian.Uint16(path[i:])
+			if c == 0 {
+				break
+			}
+			chars = append(chars, c)
+		}
+		entry.origin = string(utf16.Decode(chars))
+		trashInfo["$R"+e.Name()[2:]] = entry
+	}
+}
+
+// The trash's own files, which aren't trashed files.
+func isTrashBookkeeping(f *fileitem) bool {
+	if f.Path != trashRoot {
+		return false
+	}
+	return strings.HasPrefix(f.Name, "$I") || strings.EqualFold(f.Name, "desktop.ini") || f.Name == ".DS_Store"
+}
+
+// Moves a file to the trash, for -trash.  Windows hands it to the shell so the Recycle Bin
+// records it properly; elsewhere it is moved in, with a .trashinfo on Linux and BSD.
+func trashFile(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		script := "Add-Type -AssemblyName Microsoft.VisualBasic; " +
+			"$p = $env:DIR_TRASH_PATH; " +
+			"if (Test-Path -LiteralPath $p -PathType Container) { [Microsoft.VisualBasic.FileIO.FileSystem]::DeleteDirectory($p, 'OnlyErrorDialogs', 'SendToRecycleBin') } " +
+			"else { [Microsoft.VisualBasic.FileIO.FileSystem]::DeleteFile($p, 'OnlyErrorDialogs', 'SendToRecycleBin') }"
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+		cmd.Env = append(os.Environ(), "DIR_TRASH_PATH="+path)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.New(strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	item := fileitem{IsDir: fi.IsDir(), Mode: fi.Mode(), Modified: fi.ModTime()}
+	dir := trashDirectory()
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// Don't replace something already trashed under the same name.
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(filepath.Join(dir, name)); err != nil {
+			break
+		}
+		name = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(filepath.Base(path), ext), i, ext)
+	}
+	if runtime.GOOS != "darwin" {
+		infoDir := filepath.Join(filepath.Dir(dir), "info")
+		if err = os.MkdirAll(infoDir, 0700); err != nil {
+			return err
+		}
+		info := "[Trash Info]\nPath=" + (&url.URL{Path: path}).EscapedPath() +
+			"\nDeletionDate=" + time.Now().Format("2006-01-02T15:04:05") + "\n"
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		if err = os.WriteFile(infoPath, []byte(info), 0600); err != nil {
+			return err
+		}
+		if err = moveFile(path, filepath.Join(dir, name), item); err != nil {
+			os.Remove(infoPath)
+		}
+		return err
+	}
+	return moveFile(path, filepath.Join(dir, name), item)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// .dir.conf files: type and color settings for one tree, such as a repository, merged over the
+// config file's while listing in it.  They take the config file's syntax, e.g.
+//   type code = proto
+//   type data = pb.go
+//   color code = 01;33
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const treeConfigFile = ".dir.conf"
+
+// The settings from one .dir.conf.
+type treeConfig struct {
+	types  map[string]Filetype // By lower-case extension
+	colors map[Filetype]string
+}
+
+var (
+	use_treeconfig bool         = true
+	treeConfigs    []treeConfig // From the directories being walked, outermost first
+)
+
+// Reads target's .dir.conf, if any, over those in force.  Returns a function to call when leaving
+// the directory, which drops it again.
+func enterTreeConfig(target string) func() {
+	config := treeConfig{types: map[string]Filetype{}, colors: map[Filetype]string{}}
+	found := readConfigFile(filepath.Join(target, treeConfigFile), func(kind string, name string, value string, path string, lineNo int) {
+		switch kind {
+		case "type":
+			if ft, ok := configType(name, path, lineNo); ok {
+				for _, ext := range configExtensions(value) {
+					config.types[ext] = ft
+				}
+			}
+		case "color":
+			if ft, ok := configType(name, path, lineNo); ok {
+				config.colors[ft] = value
+			}
+		default:
+			configError(path, lineNo, "only type and color settings are allowed here, not %s\n", kind)
+		}
+	})
+	if !found {
+		return func() {}
+	}
+	conditionalPrint(debug_messages, "Settings from %s\n", filepath.Join(target, treeConfigFile))
+	treeConfigs = append(treeConfigs, config)
+	return func() { treeConfigs = treeConfigs[:len(treeConfigs)-1] }
+}
+
+// Reads the .dir.conf files above target, outermost first, for listing inside a tree that has
+// one.  Returns a function that drops them again.
+func enterParentTreeConfigs(target string) func() {
+	dir, err := filepath.Abs(target)
+	if err != nil {
+		return func() {}
+	}
+	var parents []string
+	for parent := filepath.Dir(dir); parent != dir; dir, parent = parent, filepath.Dir(parent) {
+		parents = append(parents, parent)
+	}
+	before := len(treeConfigs)
+	for i := len(parents) - 1; i >= 0; i-- {
+		enterTreeConfig(parents[i])
+	}
+	return func() { treeConfigs = treeConfigs[:before] }
+}
+
+// The type for a file's extension: the innermost .dir.conf's, else the config file's or the
+// built-in one.  The longest extension wins, so pb.go comes before go.
+func typeForName(name string) (Filetype, bool) {
+	for i := 1; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+		ext := strings.ToLower(name[i+1:])
+		for j := len(treeConfigs) - 1; j >= 0; j-- {
+			if ft, found := treeConfigs[j].types[ext]; found {
+				return ft, true
+			}
+		}
+		if ft, found := extensionTypes[ext]; found {
+			return ft, true
+		}
+	}
+	return NONE, false
+}
+
+// Settles a file's type and color while the .dir.conf settings for its directory are in force, as
+// it may be printed after leaving it.
+func applyTreeConfig(f *fileitem) {
+	ft := f.FileType()
+	for j := len(treeConfigs) - 1; j >= 0; j-- {
+		if color, found := treeConfigs[j].colors[ft]; found {
+			f._color = color
+			return
+		}
+	}
+}
+
+// The escape sequence for the file's color: its type's, or a .dir.conf's.
+func (f fileitem) colorString() string {
+	if len(f._color) > 0 {
+		return fmt.Sprintf("\033[%sm", f._color)
+	}
+	if !use_enhanced_colors && f.FileType() >= DOCUMENT && f.FileType() < DIRECTORY {
+		return colorSetString(DEFAULT) // Because not enhanced.
+	}
+	return colorSetString(f.FileType())
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Version details for other tools: the schema stamp in JSON and CSV output, and -version=json.
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// The version of the JSON and CSV formats.  Bump it when a change could break what reads them:
+// a field renamed, removed or changing meaning.  New fields don't need it.
+const outputSchema = 1
+
+type versionInfo struct {
+	Version   string            `json:"version"`
+	Schema    int               `json:"schema"`
+	GoVersion string            `json:"go"`
+	Platform  string            `json:"platform"` // GOOS/GOARCH
+	Revision  string            `json:"revision,omitempty"`
+	Config    string            `json:"config,omitempty"`
+	Features  map[string]string `json:"features"`
+}
+
+// Prints the version, build and what this build can do on this platform, as JSON.
+func printVersionJSON() {
+	info := versionInfo{Version: versionDate, Schema: outputSchema, GoVersion: runtime.Version(),
+		Platform: runtime.GOOS + "/" + runtime.GOARCH, Config: configPath}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Revision = setting.Value
+			}
+		}
+	}
+	pdfHelper, _ := resolvePDFHelper()
+	var archives []string
+	for _, handler := range archiveHandlers {
+		archives = append(archives, handler.Name())
+	}
+	info.Features = map[string]string{
+		"created-time":    createdTimeSource, // birth, or the inode change time
+		"owner":           "yes",
+		"group":           ternaryString(runtime.GOOS == "windows", "no", "yes"),
+		"code-signatures": ternaryString(signaturesChecked, "yes", "no"),
+		"pdf-helper":      pdfHelper,
+		"archives":        strings.Join(archives, ","),
+	}
+	data, _ := json.MarshalIndent(info, "", " ")
+	fmt.Fprintln(output, string(data))
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Counts of what the walk couldn't read - directories that wouldn't open, entries whose details
+// couldn't be read - for the summary and JSON, so nothing is left out without a word.  A directory
+// that times out may still be read in the background, so these are locked.
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+)
+
+var walkStats struct {
+	sync.Mutex
+	directories      []string // Couldn't be read
+	files            int      // Details couldn't be read
+	permissionDenied int      // Of both
+}
+
+// Notes a directory that couldn't be read.
+func noteUnreadableDirectory(target string, err error) {
+	walkStats.Lock()
+	defer walkStats.Unlock()
+	walkStats.directories = append(walkStats.directories, target)
+	if errors.Is(err, fs.ErrPermission) {
+		walkStats.permissionDenied++
+	}
+}
+
+// Notes the entries of a directory whose details couldn't be read, once it has been read.
+func noteUnreadableFiles(count int, denied int) {
+	walkStats.Lock()
+	defer walkStats.Unlock()
+	walkStats.files += count
+	walkStats.permissionDenied += denied
+}
+
+func printWalkStats() {
+	walkStats.Lock()
+	defer walkStats.Unlock()
+	if len(walkStats.directories) > 0 {
+		fmt.Fprintf(output, "   %4d Directories could not be read.\n", len(walkStats.directories))
+	}
+	if walkStats.files > 0 {
+		fmt.Fprintf(output, "   %4d Files could not be examined.\n", walkStats.files)
+	}
+	if walkStats.permissionDenied > 0 {
+		fmt.Fprintf(output, "   %4d of those were permission denied.\n", walkStats.permissionDenied)
+	}
+}
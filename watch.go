@@ -0,0 +1,128 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -watch: keeps a live summary of a directory, such as a download or ingest folder.  The tree is
+// looked at every few seconds, and when it has changed the counts and newest files are printed
+// again, at most once per -interval=, however busy it is.
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	watchNewest = 5               // Newest files shown in each summary
+	watchPoll   = 2 * time.Second // How often the tree is looked at, at most
+)
+
+var (
+	watch_mode     bool
+	watch_interval = 30 * time.Second // The most often the summary is printed again
+)
+
+// Prints the summary, then again whenever what matches changes, until -maxtime or interrupted.
+func watchDirectory(target string) {
+	poll := watchPoll
+	if watch_interval < poll {
+		poll = watch_interval
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	var printed time.Time
+	var last uint64
+	pending := true
+	for {
+		files := watchScan(target)
+		if fingerprint := watchFingerprint(files); fingerprint != last {
+			last = fingerprint
+			pending = true
+		}
+		if pending && time.Since(printed) >= watch_interval {
+			printWatchSummary(target, files)
+			printed = time.Now()
+			pending = false
+		}
+		select {
+		case <-runContext.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// What matches in dir, and below it with -r.
+func watchScan(dir string) []fileitem {
+	files, err := source.ListDir(dir, func(fi *fileitem) bool {
+		fi._matched = fileMeetsConditions(fi)
+		return fi._matched || fi.IsDir
+	})
+	if err != nil {
+		conditionalPrint(show_errors, "Could not read %s: %s\n", dir, err.Error())
+		return nil
+	}
+	var matched []fileitem
+	for _, f := range files {
+		if f._matched {
+			matched = append(matched, f)
+		}
+		if f.IsDir && recurse_directories && (listhidden || f.Name[0] != '.') {
+			matched = append(matched, watchScan(filepath.Join(dir, f.Name))...)
+		}
+	}
+	return matched
+}
+
+// Changes when a file is added, removed, renamed, or changes size or time.
+func watchFingerprint(files []fileitem) uint64 {
+	hash := fnv.New64a()
+	for _, f := range files {
+		fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%d\n", f.Path, f.Name, f.Size, f.Modified.UnixNano())
+	}
+	return hash.Sum64()
+}
+
+func printWatchSummary(target string, files []fileitem) {
+	filecount, dircount, bytes := 0, 0, int64(0)
+	var newest []fileitem
+	for _, f := range files {
+		if f.IsDir {
+			dircount++
+			continue
+		}
+		filecount++
+		bytes += f.Size
+		newest = append(newest, f)
+	}
+	sort.SliceStable(newest, func(i, j int) bool { return newest[i].Modified.After(newest[j].Modified) })
+	if len(newest) > watchNewest {
+		newest = newest[:watchNewest]
+	}
+	fmt.Fprintf(output, "\n   %s  %s: %4d Files (%s bytes) and %4d Directories.\n", time.Now().Format("15:04:05"), displayPath(target),
+		filecount, FileSizeToString(bytes), dircount)
+	for _, f := range newest {
+		name := f.Name
+		if rel, err := filepath.Rel(target, filepath.Join(f.Path, f.Name)); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		fmt.Fprintf(output, "          %s  %s  %s\n", formatTime(f.Modified), FileSizeToString(f.Size), name)
+	}
+	flushOutput() // Each summary as it's made, as watching runs until stopped
+}
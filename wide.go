@@ -0,0 +1,74 @@
+/*
+Copyright 2024, RoboMac
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// -wide (DOS /w): only the names, across the screen in as many columns as fit, directories in
+// [brackets], for a quick look over a big directory.
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const wideGap = 2 // Spaces between columns
+
+var wide_view bool
+
+// Screen width, from $COLUMNS if the shell exports it.
+func screenColumns() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 10 {
+		return n
+	}
+	return 80
+}
+
+// Prints the names in rows, left to right, each column as wide as the longest name.
+func printWide(files []fileitem) {
+	names := make([]string, len(files))
+	longest := 0
+	for i, f := range files {
+		names[i] = f.DisplayName()
+		if f.IsDir {
+			names[i] = "[" + names[i] + "]"
+		}
+		if n := utf8.RuneCountInString(names[i]); n > longest {
+			longest = n
+		}
+	}
+	perRow := (screenColumns() + wideGap) / (longest + wideGap)
+	if perRow < 1 {
+		perRow = 1
+	}
+	var line strings.Builder
+	for i, f := range files {
+		last := (i+1)%perRow == 0 || i == len(files)-1
+		if use_colors {
+			line.WriteString(f.colorString() + names[i] + colorSetString(NONE))
+		} else {
+			line.WriteString(names[i])
+		}
+		if !last {
+			line.WriteString(strings.Repeat(" ", longest+wideGap-utf8.RuneCountInString(names[i])))
+		} else {
+			fmt.Fprintln(output, line.String())
+			line.Reset()
+		}
+	}
+}